@@ -0,0 +1,179 @@
+package v1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConditionType is a type of Repo condition
+type ConditionType string
+
+const (
+	// ConditionReady is true when the latest commit was fetched, its config is
+	// valid and its pipeline succeeded
+	ConditionReady ConditionType = "Ready"
+
+	// ConditionFetched is true when the branch head and alaska.yaml were read from the git provider
+	ConditionFetched ConditionType = "Fetched"
+
+	// ConditionConfigValid is true when alaska.yaml was parsed and turned into a Pipeline
+	ConditionConfigValid ConditionType = "ConfigValid"
+
+	// ConditionDeploying is true while the latest PipelineRun is running or a
+	// new commit is queued behind it
+	ConditionDeploying ConditionType = "Deploying"
+
+	// ConditionSuspended is true while the controller doesn't look for new
+	// commits or start PipelineRuns for the Repo
+	ConditionSuspended ConditionType = "Suspended"
+)
+
+const (
+	// ReasonFetched means the branch head and config were read
+	ReasonFetched = "Fetched"
+
+	// ReasonInvalidURL means spec.url isn't a URL the Repo's provider understands
+	ReasonInvalidURL = "InvalidURL"
+
+	// ReasonCredentialsError means the Repo's git credentials couldn't be read or set up
+	ReasonCredentialsError = "CredentialsError"
+
+	// ReasonBranchLookupFailed means the head of spec.branch couldn't be found
+	ReasonBranchLookupFailed = "BranchLookupFailed"
+
+	// ReasonInvalidRevision means spec.revision isn't a commit SHA
+	ReasonInvalidRevision = "InvalidRevision"
+
+	// ReasonConfigFetchFailed means the config file, or a file it includes, couldn't be read at the branch head
+	ReasonConfigFetchFailed = "ConfigFetchFailed"
+
+	// ReasonValid means alaska.yaml was parsed and its Pipeline is up to date
+	ReasonValid = "Valid"
+
+	// ReasonParseError means a config file isn't valid YAML for a Config, has
+	// unknown fields or includes itself. The message has the file, line and column.
+	ReasonParseError = "ParseError"
+
+	// ReasonInvalidConfig means alaska.yaml parsed but fails validation, or asks
+	// for something the controller can't provide
+	ReasonInvalidConfig = "InvalidConfig"
+
+	// ReasonPipelineError means the Pipeline for alaska.yaml couldn't be created or updated
+	ReasonPipelineError = "PipelineError"
+
+	// ReasonTriggerFailed means a PipelineRun couldn't be created for a new commit
+	ReasonTriggerFailed = "TriggerFailed"
+
+	// ReasonRunning means the latest PipelineRun hasn't finished
+	ReasonRunning = "Running"
+
+	// ReasonQueued means a new commit waits for unfinished runs to finish
+	// under the queue concurrency policy
+	ReasonQueued = "Queued"
+
+	// ReasonSucceeded means the latest PipelineRun succeeded
+	ReasonSucceeded = "Succeeded"
+
+	// ReasonFailed means the latest PipelineRun failed
+	ReasonFailed = "Failed"
+
+	// ReasonNoRuns means no PipelineRun has been started yet
+	ReasonNoRuns = "NoRuns"
+
+	// ReasonSuspended means spec.suspend is set
+	ReasonSuspended = "Suspended"
+
+	// ReasonSuspendedBySelector means the Repo's labels match the
+	// controller's --suspend-selector
+	ReasonSuspendedBySelector = "SuspendedBySelector"
+
+	// ReasonResumed means a suspended Repo is reconciled again
+	ReasonResumed = "Resumed"
+)
+
+// Condition describes one aspect of a Repo's state
+type Condition struct {
+	Type   ConditionType          `json:"type"`
+	Status corev1.ConditionStatus `json:"status"`
+
+	// Reason is a CamelCase reason for the condition's last transition
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// Message is a human readable explanation of Reason
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// +optional
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// GetCondition returns the condition of type t, or nil if it isn't set
+func (s *RepoStatus) GetCondition(t ConditionType) *Condition {
+	for i := range s.Conditions {
+		if s.Conditions[i].Type == t {
+			return &s.Conditions[i]
+		}
+	}
+
+	return nil
+}
+
+// SetCondition sets the condition of type t. LastTransitionTime only
+// changes when the status does.
+func (s *RepoStatus) SetCondition(t ConditionType, status corev1.ConditionStatus, reason, message string) {
+	condition := s.GetCondition(t)
+	if condition == nil {
+		s.Conditions = append(s.Conditions, Condition{Type: t})
+		condition = &s.Conditions[len(s.Conditions)-1]
+	}
+
+	if condition.Status != status {
+		condition.Status = status
+		condition.LastTransitionTime = metav1.Now()
+	}
+
+	condition.Reason = reason
+	condition.Message = message
+}
+
+// IsSuspended returns whether the Suspended condition is true
+func (s *RepoStatus) IsSuspended() bool {
+	c := s.GetCondition(ConditionSuspended)
+	return c != nil && c.Status == corev1.ConditionTrue
+}
+
+// SetSuspended sets the Suspended condition with reason, or clears it if
+// reason is empty. Repos that were never suspended get no Suspended condition.
+func (s *RepoStatus) SetSuspended(reason, message string) {
+	switch {
+	case reason != "":
+		s.SetCondition(ConditionSuspended, corev1.ConditionTrue, reason, message)
+	case s.GetCondition(ConditionSuspended) != nil:
+		s.SetCondition(ConditionSuspended, corev1.ConditionFalse, ReasonResumed, "")
+	}
+}
+
+// SetReady derives the Ready condition from the other conditions: it is false
+// with the first failing condition's reason, unknown while deploying and true
+// once the latest PipelineRun succeeded
+func (s *RepoStatus) SetReady() {
+	for _, t := range []ConditionType{ConditionFetched, ConditionConfigValid} {
+		if c := s.GetCondition(t); c != nil && c.Status == corev1.ConditionFalse {
+			s.SetCondition(ConditionReady, corev1.ConditionFalse, c.Reason, c.Message)
+			return
+		}
+	}
+
+	deploying := s.GetCondition(ConditionDeploying)
+	switch {
+	case deploying == nil:
+		s.SetCondition(ConditionReady, corev1.ConditionUnknown, ReasonNoRuns, "")
+	case deploying.Status == corev1.ConditionTrue:
+		s.SetCondition(ConditionReady, corev1.ConditionUnknown, deploying.Reason, deploying.Message)
+	case deploying.Reason == ReasonSucceeded:
+		s.SetCondition(ConditionReady, corev1.ConditionTrue, deploying.Reason, deploying.Message)
+	default:
+		s.SetCondition(ConditionReady, corev1.ConditionFalse, deploying.Reason, deploying.Message)
+	}
+}
@@ -0,0 +1,68 @@
+package v1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+)
+
+var _ = Describe("RepoStatus condition tests", func() {
+	var status *RepoStatus
+
+	BeforeEach(func() {
+		status = &RepoStatus{}
+	})
+
+	Context("given a condition whose status doesn't change", func() {
+		It("should keep its transition time and update its reason", func() {
+			status.SetCondition(ConditionFetched, corev1.ConditionFalse, ReasonBranchLookupFailed, "404")
+			transitioned := status.GetCondition(ConditionFetched).LastTransitionTime
+
+			status.SetCondition(ConditionFetched, corev1.ConditionFalse, ReasonConfigFetchFailed, "404")
+			Expect(status.Conditions).To(HaveLen(1))
+			Expect(status.GetCondition(ConditionFetched).Reason).To(Equal(ReasonConfigFetchFailed))
+			Expect(status.GetCondition(ConditionFetched).LastTransitionTime).To(Equal(transitioned))
+		})
+	})
+
+	Context("given a failed fetch", func() {
+		It("should not be ready, with the fetch's reason", func() {
+			status.SetCondition(ConditionFetched, corev1.ConditionFalse, ReasonBranchLookupFailed, "branch not found")
+			status.SetCondition(ConditionDeploying, corev1.ConditionFalse, ReasonSucceeded, "")
+			status.SetReady()
+
+			ready := status.GetCondition(ConditionReady)
+			Expect(ready.Status).To(Equal(corev1.ConditionFalse))
+			Expect(ready.Reason).To(Equal(ReasonBranchLookupFailed))
+			Expect(ready.Message).To(Equal("branch not found"))
+		})
+	})
+
+	Context("given a running pipeline", func() {
+		It("should be unknown until the run finishes", func() {
+			status.SetCondition(ConditionFetched, corev1.ConditionTrue, ReasonFetched, "")
+			status.SetCondition(ConditionConfigValid, corev1.ConditionTrue, ReasonValid, "")
+			status.SetCondition(ConditionDeploying, corev1.ConditionTrue, ReasonRunning, "")
+			status.SetReady()
+			Expect(status.GetCondition(ConditionReady).Status).To(Equal(corev1.ConditionUnknown))
+
+			status.SetCondition(ConditionDeploying, corev1.ConditionFalse, ReasonSucceeded, "")
+			status.SetReady()
+			Expect(status.GetCondition(ConditionReady).Status).To(Equal(corev1.ConditionTrue))
+		})
+	})
+
+	Context("given a suspended Repo", func() {
+		It("should only report resuming once it was suspended", func() {
+			status.SetSuspended("", "")
+			Expect(status.GetCondition(ConditionSuspended)).To(BeNil())
+
+			status.SetSuspended(ReasonSuspended, "spec.suspend is set")
+			Expect(status.IsSuspended()).To(BeTrue())
+
+			status.SetSuspended("", "")
+			Expect(status.IsSuspended()).To(BeFalse())
+			Expect(status.GetCondition(ConditionSuspended).Reason).To(Equal(ReasonResumed))
+		})
+	})
+})
@@ -1,10 +1,15 @@
 package v1
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"fmt"
 	"path"
+	"sort"
+	"strings"
 
 	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 )
 
 type Executor string
@@ -16,10 +21,76 @@ const (
 	// ExecutorHelm is the executor that executes helm
 	ExecutorHelm Executor = "helm"
 
+	// ExecutorKustomize is the executor that builds a kustomization and applies it with kubectl
+	ExecutorKustomize Executor = "kustomize"
+
+	// ExecutorGeneric is the executor that runs a user supplied image and command
+	ExecutorGeneric Executor = "generic"
+
 	// Executor Task name format string
 	ExecutorTaskNameFormatString = "alaska-%s-executor"
+
+	// GenericTaskNameFormatString names the Task generated for a generic
+	// manifest after a hash of its spec, so identical steps share a Task
+	GenericTaskNameFormatString = "alaska-generic-%x"
+
+	// NamespaceImage runs the step that creates a manifest's namespace
+	NamespaceImage = "andrewrudoi/kubectl:v1.15.2"
+
+	// namespaceScript creates $NAMESPACE if $CREATE_NAMESPACE is "true". The
+	// params come in through the environment, so the shell never parses them.
+	// The executor ClusterTasks in config/tasks run the same script.
+	namespaceScript = `set -eo pipefail
+if [ "$CREATE_NAMESPACE" = "true" ] && [ -n "$NAMESPACE" ]; then
+  kubectl --kubeconfig "/workspace/${inputs.resources.cluster.name}/kubeconfig" create namespace "$NAMESPACE" --dry-run -o yaml \
+    | kubectl --kubeconfig "/workspace/${inputs.resources.cluster.name}/kubeconfig" apply -f -
+fi
+`
 )
 
+// namespaceEnv passes the namespace params to namespaceScript
+var namespaceEnv = []corev1.EnvVar{
+	{Name: "NAMESPACE", Value: "${inputs.params.namespace}"},
+	{Name: "CREATE_NAMESPACE", Value: "${inputs.params.createNamespace}"},
+}
+
+// Tool is a command line tool an executor image provides
+type Tool string
+
+const (
+	ToolKubectl   Tool = "kubectl"
+	ToolHelm      Tool = "helm"
+	ToolKustomize Tool = "kustomize"
+)
+
+// Tools pins the version of each tool an executor runs
+type Tools map[Tool]string
+
+// ToolImages maps each tool's versions to the executor image providing that
+// version. It's controller config, so versions in alaska.yaml are only
+// labels an operator has chosen to offer, e.g. "v1.16.x" or "3.x".
+type ToolImages map[Tool]map[string]string
+
+// Image returns the image providing version of tool
+func (ti ToolImages) Image(tool Tool, version string) (string, error) {
+	versions, ok := ti[tool]
+	if !ok {
+		return "", fmt.Errorf("unknown tool %q", tool)
+	}
+
+	image, ok := versions[version]
+	if !ok {
+		known := []string{}
+		for v := range versions {
+			known = append(known, v)
+		}
+		sort.Strings(known)
+		return "", fmt.Errorf("unknown %s version %q, expected one of %v", tool, version, known)
+	}
+
+	return image, nil
+}
+
 type Strategy string
 
 const (
@@ -29,17 +100,113 @@ const (
 
 // Config is repo config
 type Config struct {
-	Manifests []*ManifestOptions `json:"paths,omitempty"`
+	Manifests []*ManifestOptions `json:"manifests,omitempty"`
 	Strategy  Strategy           `json:"strategy,omitempty"`
+
+	// Tools are the tool versions used by every manifest that doesn't pin its own
+	Tools Tools `json:"tools,omitempty"`
+
+	// Namespace is the namespace of every manifest that doesn't set its own.
+	// If empty, the target kubeconfig's namespace is used.
+	Namespace string `json:"namespace,omitempty"`
+
+	// CreateNamespace creates each manifest's namespace if it's missing
+	CreateNamespace bool `json:"createNamespace,omitempty" yaml:"createNamespace,omitempty"`
+
+	// Stages run one after another, replacing Manifests
+	Stages []*Stage `json:"stages,omitempty"`
+
+	// Include lists other config files merged into this one, relative to
+	// this file's directory or, starting with a /, to the repo root
+	Include []string `json:"include,omitempty"`
+}
+
+// Stage is a group of manifests deployed after those of the previous stage
+type Stage struct {
+	Name string `json:"name"`
+
+	// Strategy orders the stage's manifests. Defaults to the config's strategy.
+	Strategy Strategy `json:"strategy,omitempty"`
+
+	Manifests []*ManifestOptions `json:"manifests,omitempty"`
 }
 
 // ManifestOptions describes the path to a manifest and its type
 type ManifestOptions struct {
 	Path string   `json:"path,omitempty"`
 	Type Executor `json:"type,omitempty"`
+
+	// Name names the manifest's Pipeline task, so other manifests can depend on it
+	Name string `json:"name,omitempty"`
+
+	// DependsOn lists the names of manifests that must be deployed before
+	// this one, on top of any ordering from the strategy
+	DependsOn []string `json:"dependsOn,omitempty" yaml:"dependsOn,omitempty"`
+
+	// LoadRestrictor is passed to kustomize build as --load_restrictor:
+	// "rootOnly", the default, or "none" to allow a kustomization to
+	// reference files outside its root
+	LoadRestrictor string `json:"loadRestrictor,omitempty" yaml:"loadRestrictor,omitempty"`
+
+	// Image, Command, Args and Env describe the container the generic
+	// executor runs. It starts in the manifest's path within the repo, with
+	// KUBECONFIG pointing at the target cluster.
+	Image   string            `json:"image,omitempty" yaml:"image,omitempty"`
+	Command []string          `json:"command,omitempty" yaml:"command,omitempty"`
+	Args    []string          `json:"args,omitempty" yaml:"args,omitempty"`
+	Env     map[string]string `json:"env,omitempty" yaml:"env,omitempty"`
+
+	// Tools overrides the config's tool versions for this manifest
+	Tools Tools `json:"tools,omitempty"`
+
+	// Namespace is passed to kubectl and helm as --namespace, and to generic
+	// executors as $NAMESPACE. It overrides the config's namespace.
+	Namespace string `json:"namespace,omitempty"`
+
+	// CreateNamespace creates the namespace if it's missing, even if the
+	// config doesn't
+	CreateNamespace bool `json:"createNamespace,omitempty" yaml:"createNamespace,omitempty"`
+
+	// Release is the helm release name. Defaults to the last element of the
+	// path, or of the chart for remote charts.
+	Release string `json:"release,omitempty"`
+
+	// ValuesFiles are passed to helm as --values, relative to the repo root
+	ValuesFiles []string `json:"valuesFiles,omitempty" yaml:"valuesFiles,omitempty"`
+
+	// Set is passed to helm as --set
+	Set map[string]string `json:"set,omitempty"`
+
+	// Chart installs a remote chart instead of the one at the path: a chart
+	// name in RepoURL, a chart reference helm resolves itself (e.g.
+	// stable/nginx or a .tgz URL), or an oci:// reference to a chart in a
+	// registry
+	Chart string `json:"chart,omitempty"`
+
+	// RepoURL is the chart repository Chart is installed from
+	RepoURL string `json:"repoURL,omitempty" yaml:"repoURL,omitempty"`
+
+	// Version is the remote chart's version, or its tag in an OCI registry
+	Version string `json:"version,omitempty"`
 }
 
-func (c *Config) ToPipelineSpec() tektonv1.PipelineSpec {
+// ToPipelineSpec returns the Pipeline running every manifest. The config must
+// pass Validate, and tool versions are resolved to executor images through
+// images, so any version it doesn't know is an error.
+func (c *Config) ToPipelineSpec(images ToolImages) (tektonv1.PipelineSpec, error) {
+	if errs := c.Validate(); len(errs) > 0 {
+		return tektonv1.PipelineSpec{}, errs.ToAggregate()
+	}
+
+	if err := c.validateTools(images); err != nil {
+		return tektonv1.PipelineSpec{}, err
+	}
+
+	planned, err := c.plan()
+	if err != nil {
+		return tektonv1.PipelineSpec{}, err
+	}
+
 	pipeline := tektonv1.PipelineSpec{
 		Resources: []tektonv1.PipelineDeclaredResource{
 			{
@@ -54,7 +221,9 @@ func (c *Config) ToPipelineSpec() tektonv1.PipelineSpec {
 		Tasks: []tektonv1.PipelineTask{},
 	}
 
-	for i, manifest := range c.Manifests {
+	for _, p := range planned {
+		manifest := p.manifest
+
 		var executor Executor
 		if manifest.Type == "" {
 			executor = ExecutorDefault
@@ -62,9 +231,46 @@ func (c *Config) ToPipelineSpec() tektonv1.PipelineSpec {
 			executor = Executor(manifest.Type)
 		}
 
+		params := manifest.ToParams()
+		if version := c.toolVersion(manifest, executor.tool()); version != "" {
+			image, err := images.Image(executor.tool(), version)
+			if err != nil {
+				return tektonv1.PipelineSpec{}, err
+			}
+
+			params = append(params, tektonv1.Param{
+				Name: "image",
+				Value: tektonv1.ArrayOrString{
+					Type:      tektonv1.ParamTypeString,
+					StringVal: image,
+				},
+			})
+		}
+
+		if namespace := c.namespace(manifest); namespace != "" {
+			params = append(params, tektonv1.Param{
+				Name: "namespace",
+				Value: tektonv1.ArrayOrString{
+					Type:      tektonv1.ParamTypeString,
+					StringVal: namespace,
+				},
+			})
+
+			if c.CreateNamespace || manifest.CreateNamespace {
+				params = append(params, tektonv1.Param{
+					Name: "createNamespace",
+					Value: tektonv1.ArrayOrString{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "true",
+					},
+				})
+			}
+		}
+
 		task := tektonv1.PipelineTask{
-			Name:   fmt.Sprintf("task-%d", i),
-			Params: manifest.ToParams(),
+			Name:     p.name,
+			Params:   params,
+			RunAfter: p.runAfter,
 			Resources: &tektonv1.PipelineTaskResources{
 				Inputs: []tektonv1.PipelineTaskInputResource{
 					{
@@ -83,13 +289,149 @@ func (c *Config) ToPipelineSpec() tektonv1.PipelineSpec {
 			},
 		}
 
-		if c.Strategy == StrategySequential && i > 0 {
-			task.RunAfter = []string{fmt.Sprintf("task-%d", i-1)}
+		if executor == ExecutorGeneric {
+			task.TaskRef = tektonv1.TaskRef{
+				Name: manifest.GenericTaskName(),
+				Kind: tektonv1.NamespacedTaskKind,
+			}
 		}
 
 		pipeline.Tasks = append(pipeline.Tasks, task)
 	}
-	return pipeline
+	return pipeline, nil
+}
+
+// validateTools checks every tool version the config declares, including
+// those no manifest ends up using, so typos don't go unnoticed
+func (c *Config) validateTools(images ToolImages) error {
+	check := func(tools Tools) error {
+		for tool, version := range tools {
+			if _, err := images.Image(tool, version); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := check(c.Tools); err != nil {
+		return err
+	}
+
+	for _, manifest := range c.allManifests() {
+		if err := check(manifest.Tools); err != nil {
+			return fmt.Errorf("%s: %v", manifest.Path, err)
+		}
+	}
+
+	return nil
+}
+
+// namespace returns the namespace the manifest is deployed to, or "" for the
+// kubeconfig's
+func (c *Config) namespace(manifest *ManifestOptions) string {
+	if manifest.Namespace != "" {
+		return manifest.Namespace
+	}
+
+	return c.Namespace
+}
+
+// toolVersion returns the version of tool the manifest runs, or "" for the
+// executor's default image
+func (c *Config) toolVersion(manifest *ManifestOptions, tool Tool) string {
+	if version, ok := manifest.Tools[tool]; ok {
+		return version
+	}
+
+	return c.Tools[tool]
+}
+
+// GenericTasks returns the spec of the Task each generic manifest runs, keyed
+// by the Task name ToPipelineSpec references. Tekton v0.6 can't embed a
+// TaskSpec in a Pipeline, so these have to exist next to it.
+func (c *Config) GenericTasks() map[string]tektonv1.TaskSpec {
+	tasks := map[string]tektonv1.TaskSpec{}
+	for _, manifest := range c.allManifests() {
+		if manifest.Type == ExecutorGeneric {
+			tasks[manifest.GenericTaskName()] = manifest.ToTaskSpec()
+		}
+	}
+
+	return tasks
+}
+
+// ToTaskSpec returns the spec of a Task running the manifest's container with
+// the repo and cluster resources mounted like the kubectl executor's
+func (mo *ManifestOptions) ToTaskSpec() tektonv1.TaskSpec {
+	env := []corev1.EnvVar{}
+	for name, value := range mo.Env {
+		env = append(env, corev1.EnvVar{Name: name, Value: value})
+	}
+	sort.Slice(env, func(i, j int) bool { return env[i].Name < env[j].Name })
+	env = append(env,
+		corev1.EnvVar{Name: "KUBECONFIG", Value: "/workspace/${inputs.resources.cluster.name}/kubeconfig"},
+		corev1.EnvVar{Name: "NAMESPACE", Value: "${inputs.params.namespace}"},
+	)
+
+	return tektonv1.TaskSpec{
+		Inputs: &tektonv1.Inputs{
+			Params: []tektonv1.ParamSpec{
+				{
+					Name: "path",
+					Type: tektonv1.ParamTypeString,
+				},
+				{
+					Name:    "namespace",
+					Type:    tektonv1.ParamTypeString,
+					Default: &tektonv1.ArrayOrString{Type: tektonv1.ParamTypeString},
+				},
+				{
+					Name:    "createNamespace",
+					Type:    tektonv1.ParamTypeString,
+					Default: &tektonv1.ArrayOrString{Type: tektonv1.ParamTypeString, StringVal: "false"},
+				},
+			},
+			Resources: []tektonv1.TaskResource{
+				{
+					Name: "repo",
+					Type: tektonv1.PipelineResourceTypeGit,
+				},
+				{
+					Name: "cluster",
+					Type: tektonv1.PipelineResourceTypeCluster,
+				},
+			},
+		},
+		Steps: []tektonv1.Step{
+			{
+				Container: corev1.Container{
+					Name:    "create-namespace",
+					Image:   NamespaceImage,
+					Command: []string{"/bin/sh", "-c"},
+					Args:    []string{namespaceScript},
+					Env:     namespaceEnv,
+				},
+			},
+			{
+				Container: corev1.Container{
+					Name:       "run",
+					Image:      mo.Image,
+					Command:    mo.Command,
+					Args:       mo.Args,
+					Env:        env,
+					WorkingDir: "/workspace/repo/${inputs.params.path}",
+				},
+			},
+		},
+	}
+}
+
+// GenericTaskName returns the name of the Task a generic manifest runs
+func (mo *ManifestOptions) GenericTaskName() string {
+	// json.Marshal sorts map keys, so equal specs always hash the same
+	spec, _ := json.Marshal(mo.ToTaskSpec())
+	sum := sha256.Sum256(spec)
+	return fmt.Sprintf(GenericTaskNameFormatString, sum[:6])
 }
 
 func (mo *ManifestOptions) ToParams() (params []tektonv1.Param) {
@@ -102,11 +444,15 @@ func (mo *ManifestOptions) ToParams() (params []tektonv1.Param) {
 	})
 
 	if mo.Type == ExecutorHelm {
+		params = append(params, mo.helmParams()...)
+	}
+
+	if mo.Type == ExecutorKustomize && mo.LoadRestrictor != "" {
 		params = append(params, tektonv1.Param{
-			Name: "release",
+			Name: "loadRestrictor",
 			Value: tektonv1.ArrayOrString{
 				Type:      tektonv1.ParamTypeString,
-				StringVal: path.Base(mo.Path),
+				StringVal: mo.LoadRestrictor,
 			},
 		})
 	}
@@ -114,6 +460,80 @@ func (mo *ManifestOptions) ToParams() (params []tektonv1.Param) {
 	return
 }
 
+// helmParams returns the helm executor's params, leaving out those that aren't set
+func (mo *ManifestOptions) helmParams() []tektonv1.Param {
+	params := []tektonv1.Param{stringParam("release", mo.release())}
+
+	if mo.Chart != "" {
+		params = append(params, stringParam("chart", mo.Chart))
+	}
+
+	if mo.RepoURL != "" {
+		params = append(params, stringParam("repoURL", mo.RepoURL))
+	}
+
+	if mo.Version != "" {
+		params = append(params, stringParam("version", mo.Version))
+	}
+
+	if len(mo.ValuesFiles) > 0 {
+		// helm splits --values on commas
+		params = append(params, stringParam("valuesFiles", strings.Join(mo.ValuesFiles, ",")))
+	}
+
+	if len(mo.Set) > 0 {
+		set := []string{}
+		for key, value := range mo.Set {
+			set = append(set, key+"="+value)
+		}
+		sort.Strings(set)
+
+		// helm splits --set on commas too, so values containing them must escape them
+		params = append(params, stringParam("set", strings.Join(set, ",")))
+	}
+
+	return params
+}
+
+// release returns the helm release name
+func (mo *ManifestOptions) release() string {
+	if mo.Release != "" {
+		return mo.Release
+	}
+
+	if mo.Chart != "" {
+		// drop an OCI reference's tag
+		return strings.SplitN(path.Base(mo.Chart), ":", 2)[0]
+	}
+
+	return path.Base(mo.Path)
+}
+
+func stringParam(name, value string) tektonv1.Param {
+	return tektonv1.Param{
+		Name: name,
+		Value: tektonv1.ArrayOrString{
+			Type:      tektonv1.ParamTypeString,
+			StringVal: value,
+		},
+	}
+}
+
+// tool returns the tool whose version picks the executor's image, or "" if
+// the image is chosen some other way
+func (e Executor) tool() Tool {
+	switch e {
+	case ExecutorDefault:
+		return ToolKubectl
+	case ExecutorHelm:
+		return ToolHelm
+	case ExecutorKustomize:
+		return ToolKustomize
+	default:
+		return ""
+	}
+}
+
 func (e Executor) toTaskName() string {
 	return fmt.Sprintf(ExecutorTaskNameFormatString, string(e))
 }
@@ -4,6 +4,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
 )
 
 var _ = Describe("Config.ToPipelineSpec tests", func() {
@@ -65,7 +66,8 @@ var _ = Describe("Config.ToPipelineSpec tests", func() {
 		})
 
 		It("should return a pipeline spec with one task", func() {
-			pipeline := cfg.ToPipelineSpec()
+			pipeline, err := cfg.ToPipelineSpec(nil)
+			Expect(err).NotTo(HaveOccurred())
 			Expect(pipeline).To(Equal(expected))
 		})
 	})
@@ -101,11 +103,279 @@ var _ = Describe("Config.ToPipelineSpec tests", func() {
 				},
 			}
 
-			pipeline := cfg.ToPipelineSpec()
+			pipeline, err := cfg.ToPipelineSpec(nil)
+			Expect(err).NotTo(HaveOccurred())
 			Expect(pipeline).To(Equal(expected))
 		})
 	})
 
+	Context("given helm values and overrides", func() {
+		BeforeEach(func() {
+			cfg = &Config{
+				Manifests: []*ManifestOptions{
+					{
+						Path:        "path/to/chart",
+						Type:        ExecutorHelm,
+						Release:     "test",
+						ValuesFiles: []string{"values/common.yaml", "values/production.yaml"},
+						Set:         map[string]string{"replicas": "3", "image.tag": "v1.0.0"},
+					},
+				},
+			}
+		})
+
+		It("should pass the release, values files and overrides", func() {
+			pipeline, err := cfg.ToPipelineSpec(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pipeline.Tasks[0].Params).To(Equal([]tektonv1.Param{
+				stringParam("path", "path/to/chart"),
+				stringParam("release", "test"),
+				stringParam("valuesFiles", "values/common.yaml,values/production.yaml"),
+				stringParam("set", "image.tag=v1.0.0,replicas=3"),
+			}))
+		})
+	})
+
+	Context("given a chart from a remote repository", func() {
+		BeforeEach(func() {
+			cfg = &Config{
+				Manifests: []*ManifestOptions{
+					{
+						Type:        ExecutorHelm,
+						Chart:       "nginx-ingress",
+						RepoURL:     "https://kubernetes-charts.storage.googleapis.com",
+						Version:     "1.24.0",
+						ValuesFiles: []string{"ingress/values.yaml"},
+					},
+				},
+			}
+		})
+
+		It("should name the release after the chart", func() {
+			pipeline, err := cfg.ToPipelineSpec(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pipeline.Tasks[0].Params).To(Equal([]tektonv1.Param{
+				stringParam("path", ""),
+				stringParam("release", "nginx-ingress"),
+				stringParam("chart", "nginx-ingress"),
+				stringParam("repoURL", "https://kubernetes-charts.storage.googleapis.com"),
+				stringParam("version", "1.24.0"),
+				stringParam("valuesFiles", "ingress/values.yaml"),
+			}))
+		})
+
+		It("should name the release after an OCI chart without its tag", func() {
+			cfg.Manifests[0].Chart = "oci://registry.example.com:5000/charts/nginx:1.0.0"
+			Expect(cfg.Manifests[0].release()).To(Equal("nginx"))
+		})
+
+		It("should reject a helm manifest without a path or chart", func() {
+			cfg.Manifests[0].Chart = ""
+			_, err := cfg.ToPipelineSpec(nil)
+			Expect(err).To(MatchError(ContainSubstring("manifests[0].path: Required value: helm manifests need a path or a chart")))
+		})
+
+		It("should reject a repoURL without a chart", func() {
+			cfg.Manifests[0].Chart = ""
+			cfg.Manifests[0].Path = "path/to/chart"
+			_, err := cfg.ToPipelineSpec(nil)
+			Expect(err).To(MatchError("[manifests[0].repoURL: Forbidden: repoURL needs a chart, manifests[0].version: Forbidden: version needs a chart]"))
+		})
+	})
+
+	Context("given a kustomize overlay with a load restrictor", func() {
+		BeforeEach(func() {
+			cfg = &Config{
+				Manifests: []*ManifestOptions{
+					{
+						Path:           "overlays/production",
+						Type:           ExecutorKustomize,
+						LoadRestrictor: "none",
+					},
+				},
+			}
+		})
+
+		It("should return a pipeline spec with one kustomize task", func() {
+			expected.Tasks[0].TaskRef.Name = "alaska-kustomize-executor"
+			expected.Tasks[0].Params = []tektonv1.Param{
+				{
+					Name: "path",
+					Value: tektonv1.ArrayOrString{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "overlays/production",
+					},
+				},
+				{
+					Name: "loadRestrictor",
+					Value: tektonv1.ArrayOrString{
+						Type:      tektonv1.ParamTypeString,
+						StringVal: "none",
+					},
+				},
+			}
+
+			pipeline, err := cfg.ToPipelineSpec(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pipeline).To(Equal(expected))
+		})
+	})
+
+	Context("given a generic manifest", func() {
+		var manifest *ManifestOptions
+
+		BeforeEach(func() {
+			manifest = &ManifestOptions{
+				Path:    "migrations",
+				Type:    ExecutorGeneric,
+				Image:   "migrate/migrate:v4.6.2",
+				Command: []string{"migrate"},
+				Args:    []string{"-path", ".", "up"},
+				Env:     map[string]string{"DATABASE_URL": "postgres://db", "A": "b"},
+			}
+			cfg = &Config{Manifests: []*ManifestOptions{manifest}}
+		})
+
+		It("should reference a namespaced Task generated for the manifest", func() {
+			pipeline, err := cfg.ToPipelineSpec(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pipeline.Tasks[0].TaskRef).To(Equal(tektonv1.TaskRef{
+				Name: manifest.GenericTaskName(),
+				Kind: tektonv1.NamespacedTaskKind,
+			}))
+			Expect(pipeline.Tasks[0].Resources).To(Equal(expected.Tasks[0].Resources))
+			Expect(cfg.GenericTasks()).To(HaveKey(manifest.GenericTaskName()))
+		})
+
+		It("should run the image in the manifest's path with the cluster's kubeconfig", func() {
+			step := manifest.ToTaskSpec().Steps[1]
+			Expect(step.Image).To(Equal("migrate/migrate:v4.6.2"))
+			Expect(step.Command).To(Equal([]string{"migrate"}))
+			Expect(step.Args).To(Equal([]string{"-path", ".", "up"}))
+			Expect(step.WorkingDir).To(Equal("/workspace/repo/${inputs.params.path}"))
+			Expect(step.Env).To(Equal([]corev1.EnvVar{
+				{Name: "A", Value: "b"},
+				{Name: "DATABASE_URL", Value: "postgres://db"},
+				{Name: "KUBECONFIG", Value: "/workspace/${inputs.resources.cluster.name}/kubeconfig"},
+				{Name: "NAMESPACE", Value: "${inputs.params.namespace}"},
+			}))
+		})
+
+		It("should pass the namespace params to the create-namespace step through its environment", func() {
+			step := manifest.ToTaskSpec().Steps[0]
+			Expect(step.Args[0]).NotTo(ContainSubstring("${inputs.params."))
+			Expect(step.Env).To(Equal([]corev1.EnvVar{
+				{Name: "NAMESPACE", Value: "${inputs.params.namespace}"},
+				{Name: "CREATE_NAMESPACE", Value: "${inputs.params.createNamespace}"},
+			}))
+		})
+
+		It("should name Tasks after their spec", func() {
+			same := *manifest
+			Expect(same.GenericTaskName()).To(Equal(manifest.GenericTaskName()))
+
+			same.Args = []string{"-path", ".", "down"}
+			Expect(same.GenericTaskName()).NotTo(Equal(manifest.GenericTaskName()))
+		})
+	})
+
+	Context("given tool versions", func() {
+		var images ToolImages
+
+		BeforeEach(func() {
+			images = ToolImages{
+				ToolKubectl: {"v1.15.x": "andrewrudoi/kubectl:v1.15.2", "v1.16.x": "andrewrudoi/kubectl:v1.16.0"},
+				ToolHelm:    {"3.x": "andrewrudoi/helm:v3.0.0-beta.2"},
+			}
+			cfg = &Config{
+				Manifests: []*ManifestOptions{
+					{Path: "test.yaml"},
+					{Path: "pinned.yaml", Tools: Tools{ToolKubectl: "v1.15.x"}},
+					{Path: "path/to/chart", Type: ExecutorHelm},
+				},
+				Tools: Tools{ToolKubectl: "v1.16.x"},
+			}
+		})
+
+		It("should pass each task the image for its version", func() {
+			pipeline, err := cfg.ToPipelineSpec(images)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pipeline.Tasks[0].Params).To(ContainElement(tektonv1.Param{
+				Name:  "image",
+				Value: tektonv1.ArrayOrString{Type: tektonv1.ParamTypeString, StringVal: "andrewrudoi/kubectl:v1.16.0"},
+			}))
+			Expect(pipeline.Tasks[1].Params).To(ContainElement(tektonv1.Param{
+				Name:  "image",
+				Value: tektonv1.ArrayOrString{Type: tektonv1.ParamTypeString, StringVal: "andrewrudoi/kubectl:v1.15.2"},
+			}))
+			Expect(pipeline.Tasks[2].Params).To(HaveLen(2))
+		})
+
+		It("should reject unknown versions", func() {
+			cfg.Tools[ToolHelm] = "2.x"
+			_, err := cfg.ToPipelineSpec(images)
+			Expect(err).To(MatchError(`unknown helm version "2.x", expected one of [3.x]`))
+		})
+
+		It("should reject unknown versions of manifests", func() {
+			cfg.Manifests[1].Tools[ToolKubectl] = "v1.17.x"
+			_, err := cfg.ToPipelineSpec(images)
+			Expect(err).To(MatchError(`pinned.yaml: unknown kubectl version "v1.17.x", expected one of [v1.15.x v1.16.x]`))
+		})
+
+		It("should reject unknown tools", func() {
+			cfg.Tools["kubecfg"] = "v0.13.0"
+			_, err := cfg.ToPipelineSpec(images)
+			Expect(err).To(MatchError(`tools[kubecfg]: Unsupported value: "kubecfg": supported values: "kubectl", "helm", "kustomize"`))
+		})
+	})
+
+	Context("given namespaces", func() {
+		namespaceParam := func(namespace string) tektonv1.Param {
+			return tektonv1.Param{
+				Name:  "namespace",
+				Value: tektonv1.ArrayOrString{Type: tektonv1.ParamTypeString, StringVal: namespace},
+			}
+		}
+		createNamespaceParam := tektonv1.Param{
+			Name:  "createNamespace",
+			Value: tektonv1.ArrayOrString{Type: tektonv1.ParamTypeString, StringVal: "true"},
+		}
+
+		BeforeEach(func() {
+			cfg = &Config{
+				Manifests: []*ManifestOptions{
+					{Path: "test.yaml"},
+					{Path: "path/to/chart", Type: ExecutorHelm, Namespace: "monitoring", CreateNamespace: true},
+				},
+				Namespace: "apps",
+			}
+		})
+
+		It("should pass each task its namespace", func() {
+			pipeline, err := cfg.ToPipelineSpec(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pipeline.Tasks[0].Params).To(ContainElement(namespaceParam("apps")))
+			Expect(pipeline.Tasks[0].Params).NotTo(ContainElement(createNamespaceParam))
+			Expect(pipeline.Tasks[1].Params).To(ContainElement(namespaceParam("monitoring")))
+			Expect(pipeline.Tasks[1].Params).To(ContainElement(createNamespaceParam))
+		})
+
+		It("should create every namespace if the config asks to", func() {
+			cfg.CreateNamespace = true
+			pipeline, err := cfg.ToPipelineSpec(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pipeline.Tasks[0].Params).To(ContainElement(createNamespaceParam))
+		})
+
+		It("should leave the namespace to the kubeconfig if none is set", func() {
+			cfg.Namespace = ""
+			pipeline, err := cfg.ToPipelineSpec(nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(pipeline.Tasks[0].Params).To(Equal(expected.Tasks[0].Params))
+		})
+	})
+
 	Context("given multiple paths and sequential execution", func() {
 		BeforeEach(func() {
 			cfg = &Config{
@@ -115,7 +385,8 @@ var _ = Describe("Config.ToPipelineSpec tests", func() {
 		})
 
 		It("should create a pipeline that executes the paths in order", func() {
-			pipeline := cfg.ToPipelineSpec()
+			pipeline, err := cfg.ToPipelineSpec(nil)
+			Expect(err).NotTo(HaveOccurred())
 			Expect(pipeline.Tasks[1].RunAfter).To(Equal([]string{"task-0"}))
 		})
 	})
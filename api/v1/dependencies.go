@@ -0,0 +1,220 @@
+package v1
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// plannedTask is a manifest with the name of its Pipeline task and the tasks
+// it runs after
+type plannedTask struct {
+	manifest *ManifestOptions
+	name     string
+	runAfter []string
+}
+
+// stages returns the config's stages. Top level manifests are a single
+// unnamed stage.
+func (c *Config) stages() []*Stage {
+	if len(c.Stages) == 0 {
+		return []*Stage{{Strategy: c.Strategy, Manifests: c.Manifests}}
+	}
+
+	return c.Stages
+}
+
+// allManifests returns every manifest in the order the config lists them
+func (c *Config) allManifests() []*ManifestOptions {
+	manifests := []*ManifestOptions{}
+	for _, stage := range c.stages() {
+		manifests = append(manifests, stage.Manifests...)
+	}
+
+	return manifests
+}
+
+// plan names each manifest's task and orders it after the tasks it must wait
+// for: the previous manifest in a sequential stage, everything the previous
+// stage ends with and the manifests it dependsOn
+func (c *Config) plan() ([]*plannedTask, error) {
+	if len(c.Stages) > 0 && len(c.Manifests) > 0 {
+		return nil, errors.New("use either manifests or stages, not both")
+	}
+
+	stages := [][]*plannedTask{}
+	byManifestName := map[string]*plannedTask{}
+	stageNames := map[string]bool{}
+
+	for _, stage := range c.stages() {
+		if len(c.Stages) > 0 {
+			if errs := validation.IsDNS1123Label(stage.Name); len(errs) > 0 {
+				return nil, fmt.Errorf("stage name %q is invalid: %s", stage.Name, strings.Join(errs, ", "))
+			}
+			if stageNames[stage.Name] {
+				return nil, fmt.Errorf("stage name %q is used more than once", stage.Name)
+			}
+			stageNames[stage.Name] = true
+		}
+
+		stageTasks := []*plannedTask{}
+		for i, manifest := range stage.Manifests {
+			task := &plannedTask{manifest: manifest, name: stage.taskName(i, manifest)}
+
+			if manifest.Name != "" {
+				if errs := validation.IsDNS1123Label(manifest.Name); len(errs) > 0 {
+					return nil, fmt.Errorf("manifest name %q is invalid: %s", manifest.Name, strings.Join(errs, ", "))
+				}
+				if _, ok := byManifestName[manifest.Name]; ok {
+					return nil, fmt.Errorf("manifest name %q is used more than once", manifest.Name)
+				}
+				byManifestName[manifest.Name] = task
+			}
+
+			stageTasks = append(stageTasks, task)
+		}
+		stages = append(stages, stageTasks)
+	}
+
+	// every task is named now, so dependsOn can point anywhere
+	tasks := []*plannedTask{}
+	previous := []string{}
+	for s, stage := range c.stages() {
+		strategy := stage.Strategy
+		if strategy == "" {
+			strategy = c.Strategy
+		}
+
+		for i, task := range stages[s] {
+			task.runAfter = append(task.runAfter, previous...)
+			if strategy == StrategySequential && i > 0 {
+				task.runAfter = append(task.runAfter, stages[s][i-1].name)
+			}
+
+			for _, dep := range task.manifest.DependsOn {
+				depTask, ok := byManifestName[dep]
+				if !ok {
+					return nil, fmt.Errorf("%s depends on unknown manifest %q", task.name, dep)
+				}
+				if !containsString(task.runAfter, depTask.name) {
+					task.runAfter = append(task.runAfter, depTask.name)
+				}
+			}
+		}
+
+		if len(stages[s]) > 0 {
+			previous = sinks(stages[s])
+		}
+		tasks = append(tasks, stages[s]...)
+	}
+
+	index := map[string]int{}
+	for i, task := range tasks {
+		if errs := validation.IsDNS1123Label(task.name); len(errs) > 0 {
+			return nil, fmt.Errorf("task name %q is invalid: %s", task.name, strings.Join(errs, ", "))
+		}
+		if _, ok := index[task.name]; ok {
+			return nil, fmt.Errorf("task name %q is used more than once", task.name)
+		}
+		index[task.name] = i
+	}
+
+	if err := checkCycles(tasks, index); err != nil {
+		return nil, err
+	}
+
+	return tasks, nil
+}
+
+// taskName returns the Pipeline task name of the stage's i-th manifest: its
+// name or index, prefixed with the stage's name if it has one
+func (s *Stage) taskName(i int, manifest *ManifestOptions) string {
+	switch {
+	case s.Name == "" && manifest.Name == "":
+		return fmt.Sprintf("task-%d", i)
+	case s.Name == "":
+		return manifest.Name
+	case manifest.Name == "":
+		return fmt.Sprintf("%s-%d", s.Name, i)
+	default:
+		return s.Name + "-" + manifest.Name
+	}
+}
+
+// sinks returns the tasks of a stage no other task in it runs after, which
+// are the ones the next stage has to wait for
+func sinks(stage []*plannedTask) []string {
+	waitedFor := map[string]bool{}
+	for _, task := range stage {
+		for _, dep := range task.runAfter {
+			waitedFor[dep] = true
+		}
+	}
+
+	names := []string{}
+	for _, task := range stage {
+		if !waitedFor[task.name] {
+			names = append(names, task.name)
+		}
+	}
+
+	return names
+}
+
+// checkCycles returns an error naming the tasks of a cycle in runAfter, if any
+func checkCycles(tasks []*plannedTask, index map[string]int) error {
+	// depth first search, a task seen again while it's still on the path is a cycle
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(tasks))
+	path := []string{}
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			start := 0
+			for start < len(path) && path[start] != tasks[i].name {
+				start++
+			}
+			return fmt.Errorf("dependency cycle: %s", strings.Join(append(path[start:], tasks[i].name), " -> "))
+		}
+
+		state[i] = visiting
+		path = append(path, tasks[i].name)
+		for _, dep := range tasks[i].runAfter {
+			if err := visit(index[dep]); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+
+		return nil
+	}
+
+	for i := range tasks {
+		if err := visit(i); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,164 @@
+package v1
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config dependencies", func() {
+	var cfg *Config
+
+	BeforeEach(func() {
+		cfg = &Config{
+			Manifests: []*ManifestOptions{
+				{Name: "crds", Path: "crds/"},
+				{Name: "operator", Path: "operator/", DependsOn: []string{"crds"}},
+				{Name: "config", Path: "config/", DependsOn: []string{"crds"}},
+				{Name: "apps", Path: "apps/", DependsOn: []string{"operator", "config"}},
+			},
+		}
+	})
+
+	It("should turn dependsOn into RunAfter edges between named tasks", func() {
+		pipeline, err := cfg.ToPipelineSpec(nil)
+		Expect(err).NotTo(HaveOccurred())
+
+		runAfter := map[string][]string{}
+		for _, task := range pipeline.Tasks {
+			runAfter[task.Name] = task.RunAfter
+		}
+		Expect(runAfter).To(Equal(map[string][]string{
+			"crds":     nil,
+			"operator": {"crds"},
+			"config":   {"crds"},
+			"apps":     {"operator", "config"},
+		}))
+	})
+
+	It("should add dependencies to the sequential chain", func() {
+		cfg.Strategy = StrategySequential
+		pipeline, err := cfg.ToPipelineSpec(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pipeline.Tasks[2].RunAfter).To(Equal([]string{"operator", "crds"}))
+		Expect(pipeline.Tasks[3].RunAfter).To(Equal([]string{"config", "operator"}))
+	})
+
+	It("should keep index names for unnamed manifests", func() {
+		cfg.Manifests = append(cfg.Manifests, &ManifestOptions{Path: "extra.yaml", DependsOn: []string{"apps"}})
+		pipeline, err := cfg.ToPipelineSpec(nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(pipeline.Tasks[4].Name).To(Equal("task-4"))
+		Expect(pipeline.Tasks[4].RunAfter).To(Equal([]string{"apps"}))
+	})
+
+	It("should reject unknown references", func() {
+		cfg.Manifests[3].DependsOn = []string{"operators"}
+		_, err := cfg.ToPipelineSpec(nil)
+		Expect(err).To(MatchError(`apps depends on unknown manifest "operators"`))
+	})
+
+	It("should reject references to index names", func() {
+		cfg.Manifests = append(cfg.Manifests, &ManifestOptions{Path: "extra.yaml"})
+		cfg.Manifests[3].DependsOn = []string{"task-4"}
+		_, err := cfg.ToPipelineSpec(nil)
+		Expect(err).To(MatchError(`apps depends on unknown manifest "task-4"`))
+	})
+
+	It("should reject duplicate names", func() {
+		cfg.Manifests[2].Name = "operator"
+		_, err := cfg.ToPipelineSpec(nil)
+		Expect(err).To(MatchError(`manifest name "operator" is used more than once`))
+	})
+
+	It("should reject names that aren't task names", func() {
+		cfg.Manifests[0].Name = "CRDs"
+		_, err := cfg.ToPipelineSpec(nil)
+		Expect(err).To(MatchError(ContainSubstring(`manifest name "CRDs" is invalid`)))
+	})
+
+	It("should reject cycles", func() {
+		cfg.Manifests[0].DependsOn = []string{"apps"}
+		_, err := cfg.ToPipelineSpec(nil)
+		Expect(err).To(MatchError("dependency cycle: crds -> apps -> operator -> crds"))
+	})
+
+	It("should reject manifests depending on themselves", func() {
+		cfg.Manifests[1].DependsOn = []string{"operator"}
+		_, err := cfg.ToPipelineSpec(nil)
+		Expect(err).To(MatchError("dependency cycle: operator -> operator"))
+	})
+
+	Context("given stages", func() {
+		BeforeEach(func() {
+			cfg = &Config{
+				Stages: []*Stage{
+					{
+						Name:      "crds",
+						Strategy:  StrategySequential,
+						Manifests: []*ManifestOptions{{Path: "crds/a.yaml"}, {Path: "crds/b.yaml"}},
+					},
+					{
+						Name:      "apps",
+						Manifests: []*ManifestOptions{{Name: "api", Path: "apps/api"}, {Name: "web", Path: "apps/web"}},
+					},
+					{
+						Name:      "smoke",
+						Manifests: []*ManifestOptions{{Path: "smoke/"}},
+					},
+				},
+			}
+		})
+
+		runAfter := func() map[string][]string {
+			pipeline, err := cfg.ToPipelineSpec(nil)
+			Expect(err).NotTo(HaveOccurred())
+
+			runAfter := map[string][]string{}
+			for _, task := range pipeline.Tasks {
+				runAfter[task.Name] = task.RunAfter
+			}
+			return runAfter
+		}
+
+		It("should run stages in order with their own strategy", func() {
+			Expect(runAfter()).To(Equal(map[string][]string{
+				"crds-0":   nil,
+				"crds-1":   {"crds-0"},
+				"apps-api": {"crds-1"},
+				"apps-web": {"crds-1"},
+				"smoke-0":  {"apps-api", "apps-web"},
+			}))
+		})
+
+		It("should fall back to the config's strategy", func() {
+			cfg.Strategy = StrategySequential
+			Expect(runAfter()).To(HaveKeyWithValue("apps-web", []string{"crds-1", "apps-api"}))
+			Expect(runAfter()).To(HaveKeyWithValue("smoke-0", []string{"apps-web"}))
+		})
+
+		It("should resolve dependsOn to the stage's task names", func() {
+			cfg.Stages[1].Manifests[1].DependsOn = []string{"api"}
+			Expect(runAfter()).To(HaveKeyWithValue("apps-web", []string{"crds-1", "apps-api"}))
+			Expect(runAfter()).To(HaveKeyWithValue("smoke-0", []string{"apps-web"}))
+		})
+
+		It("should reject depending on a later stage", func() {
+			cfg.Stages[2].Manifests[0].Name = "smoke"
+			cfg.Stages[1].Manifests[0].DependsOn = []string{"smoke"}
+			_, err := cfg.ToPipelineSpec(nil)
+			Expect(err).To(MatchError("dependency cycle: apps-api -> smoke-smoke -> apps-api"))
+		})
+
+		It("should reject duplicate stage names", func() {
+			cfg.Stages[2].Name = "apps"
+			_, err := cfg.ToPipelineSpec(nil)
+			Expect(err).To(MatchError(`stage name "apps" is used more than once`))
+		})
+
+		It("should reject stages next to top level manifests", func() {
+			cfg.Manifests = []*ManifestOptions{{Path: "test.yaml"}}
+			_, err := cfg.ToPipelineSpec(nil)
+			Expect(err).To(MatchError("use either manifests or stages, not both"))
+		})
+	})
+})
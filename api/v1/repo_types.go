@@ -17,23 +17,138 @@ limitations under the License.
 package v1
 
 import (
+	"regexp"
+
 	corev1 "k8s.io/api/core/v1"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// GitProvider is the API Alaska uses to read a Repo
+// +kubebuilder:validation:Enum=github;gitlab;git
+type GitProvider string
+
+const (
+	// ProviderGitHub reads repos through the GitHub API
+	ProviderGitHub GitProvider = "github"
+
+	// ProviderGitLab reads repos through the GitLab API
+	ProviderGitLab GitProvider = "gitlab"
+
+	// ProviderGit reads repos over the git protocol with ls-remote and a shallow fetch
+	ProviderGit GitProvider = "git"
+)
+
+// ConcurrencyPolicy says what happens to a Repo's unfinished PipelineRuns when
+// a new commit lands
+// +kubebuilder:validation:Enum=allow;queue;cancel-previous
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyAllow starts a PipelineRun for every new commit right away
+	ConcurrencyAllow ConcurrencyPolicy = "allow"
+
+	// ConcurrencyQueue starts a PipelineRun for the latest new commit once
+	// every unfinished run has finished
+	ConcurrencyQueue ConcurrencyPolicy = "queue"
+
+	// ConcurrencyCancelPrevious cancels every unfinished run before starting
+	// a PipelineRun for a new commit
+	ConcurrencyCancelPrevious ConcurrencyPolicy = "cancel-previous"
+)
+
+// GitHubSpec points a Repo at a GitHub Enterprise Server API
+type GitHubSpec struct {
+	// BaseURL is the API base URL, e.g. https://github.example.com/api/v3/
+	BaseURL string `json:"baseURL,omitempty"`
+
+	// UploadURL is the upload API base URL, defaults to BaseURL
+	// +optional
+	UploadURL string `json:"uploadURL,omitempty"`
+}
+
 // RepoSpec defines the desired state of Repo
 type RepoSpec struct {
-	URL     string `json:"url"`
+	// URL is the repository's clone URL. It can't start with -, so git
+	// never reads it as an option.
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:validation:Pattern=`^[^-\s]\S*$`
+	URL string `json:"url"`
+
 	Branch  string `json:"branch"`
 	Cluster string `json:"cluster"`
+
+	// Provider overrides the git provider inferred from the URL host
+	// +optional
+	Provider GitProvider `json:"provider,omitempty"`
+
+	// GitHub overrides the GitHub Enterprise Server API the Repo is read from
+	// +optional
+	GitHub *GitHubSpec `json:"github,omitempty"`
+
+	// CredentialsRef names a Secret in the Repo's namespace holding a token
+	// ("token"), basic auth ("username" and "password") or an SSH key
+	// ("ssh-privatekey" and optionally "known_hosts") used to read the repo.
+	// If unset, the controller's shared credentials are used.
+	// +optional
+	CredentialsRef *corev1.LocalObjectReference `json:"credentialsRef,omitempty"`
+
+	// ConfigPath is the path of the Repo's config file within the repo.
+	// Defaults to alaska.yaml.
+	// +optional
+	ConfigPath string `json:"configPath,omitempty"`
+
+	// Revision pins the Repo to a commit SHA, full or abbreviated, which is
+	// deployed instead of the branch head while it's set
+	// +kubebuilder:validation:Pattern=`^[0-9a-f]{7,40}$`
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// Suspend stops the controller from looking for new commits and starting
+	// PipelineRuns. The status of runs already started is still reported.
+	// +optional
+	Suspend bool `json:"suspend,omitempty"`
+
+	// ConcurrencyPolicy says what happens to unfinished PipelineRuns when a
+	// new commit lands. Defaults to allow.
+	// +optional
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+}
+
+// DefaultConfigPath is the config file read when spec.configPath is empty
+const DefaultConfigPath = "alaska.yaml"
+
+// commitSHARegexp matches the full or abbreviated commit SHAs spec.revision
+// accepts
+var commitSHARegexp = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// IsCommitSHA returns whether s is a commit SHA of 7 to 40 hex characters
+func IsCommitSHA(s string) bool {
+	return commitSHARegexp.MatchString(s)
 }
 
+const (
+	// AnnotationPinnedBy records who set spec.revision
+	AnnotationPinnedBy = "alpha.alaska.rudeboy.io/pinned-by"
+
+	// AnnotationPinReason records why spec.revision was set
+	AnnotationPinReason = "alpha.alaska.rudeboy.io/pin-reason"
+)
+
 type PipelineStatus struct {
 	Completed bool                    `json:"completed,omitempty"`
 	Ref       *corev1.ObjectReference `json:"ref,omitempty"`
 	Status    string                  `json:"status,omitempty"`
 	Succeeded bool                    `json:"succeeded,omitempty"`
+
+	// Commit is the commit SHA the run deploys
+	// +optional
+	Commit string `json:"commit,omitempty"`
+
+	// SupersededBy is the commit whose run cancelled this one under the
+	// cancel-previous concurrency policy
+	// +optional
+	SupersededBy string `json:"supersededBy,omitempty"`
 }
 
 // RepoStatus defines the observed state of Repo
@@ -42,11 +157,36 @@ type RepoStatus struct {
 	Config    *Config                 `json:"config,omitempty"`
 	TektonRef *corev1.ObjectReference `json:"tektonRef,omitempty"`
 	Runs      []*PipelineStatus       `json:"runs,omitempty"`
+
+	// Conditions report why a Repo is or isn't being deployed
+	// +optional
+	Conditions []Condition `json:"conditions,omitempty"`
+
+	// ObservedGeneration is the generation of the spec last reconciled
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// LastSyncTime is when the branch head and config were last read
+	// +optional
+	LastSyncTime *metav1.Time `json:"lastSyncTime,omitempty"`
+
+	// QueuedCommit is a commit waiting for unfinished runs to finish under
+	// the queue concurrency policy
+	// +optional
+	QueuedCommit string `json:"queuedCommit,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:resource:path=repos,shortName=rp
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Branch",type="string",JSONPath=".spec.branch"
+// +kubebuilder:printcolumn:name="Commit",type="string",JSONPath=".status.commitSHA"
+// +kubebuilder:printcolumn:name="Revision",type="string",JSONPath=".spec.revision",priority=1
+// +kubebuilder:printcolumn:name="Last Run",type="string",JSONPath=".status.runs[0].status"
+// +kubebuilder:printcolumn:name="Ready",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].status"
+// +kubebuilder:printcolumn:name="Reason",type="string",JSONPath=".status.conditions[?(@.type==\"Ready\")].reason",priority=1
+// +kubebuilder:printcolumn:name="Suspended",type="string",JSONPath=".status.conditions[?(@.type==\"Suspended\")].status",priority=1
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // Repo is the Schema for the repos API
 type Repo struct {
@@ -57,6 +197,24 @@ type Repo struct {
 	Status RepoStatus `json:"status,omitempty"`
 }
 
+// GetConcurrencyPolicy returns the Repo's concurrency policy
+func (r *Repo) GetConcurrencyPolicy() ConcurrencyPolicy {
+	if r.Spec.ConcurrencyPolicy == "" {
+		return ConcurrencyAllow
+	}
+
+	return r.Spec.ConcurrencyPolicy
+}
+
+// GetConfigPath returns the path of the Repo's config file
+func (r *Repo) GetConfigPath() string {
+	if r.Spec.ConfigPath == "" {
+		return DefaultConfigPath
+	}
+
+	return r.Spec.ConfigPath
+}
+
 // +kubebuilder:object:root=true
 
 // RepoList contains a list of Repo
@@ -0,0 +1,249 @@
+/*
+Copyright 2019 Andrew Rudoi.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	"k8s.io/api/admission/v1beta1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+// webhookTimeout bounds the lookups admission makes, the API server gives up
+// on webhooks after 30s
+const webhookTimeout = 10 * time.Second
+
+// FallbackBranch is the branch a Repo defaults to when its repository's
+// default branch can't be looked up
+const FallbackBranch = "master"
+
+const (
+	// MutatingWebhookPath is where the Repo defaulting webhook is served
+	MutatingWebhookPath = "/mutate-alpha-alaska-rudeboy-io-v1-repo"
+
+	// ValidatingWebhookPath is where the Repo validating webhook is served
+	ValidatingWebhookPath = "/validate-alpha-alaska-rudeboy-io-v1-repo"
+)
+
+var repolog = logf.Log.WithName("repo-resource")
+
+// RepoLookup answers the questions about a Repo that admission can't answer
+// from the Repo alone
+// +kubebuilder:object:generate=false
+type RepoLookup interface {
+	// DefaultBranch returns the default branch of the Repo's repository
+	DefaultBranch(ctx context.Context, repo *Repo) (string, error)
+
+	// ValidateURL returns an error if the Repo's provider can't read spec.url
+	ValidateURL(repo *Repo) error
+}
+
+// RepoWebhook defaults and validates Repos as they're created or updated
+// +kubebuilder:object:generate=false
+type RepoWebhook struct {
+	// Reader looks up the PipelineResources spec.cluster names. Defaults to
+	// the manager's API reader, so one created just before its Repo is found.
+	Reader client.Reader
+
+	// Lookup, if set, looks up default branches and checks URLs. Without it,
+	// branches default to FallbackBranch and URLs aren't checked.
+	Lookup RepoLookup
+
+	decoder *admission.Decoder
+}
+
+// SetupWithManager registers the Repo defaulting and validating webhooks
+// with mgr's webhook server
+func (w *RepoWebhook) SetupWithManager(mgr ctrl.Manager) error {
+	decoder, err := admission.NewDecoder(mgr.GetScheme())
+	if err != nil {
+		return err
+	}
+	w.decoder = decoder
+
+	if w.Reader == nil {
+		w.Reader = mgr.GetAPIReader()
+	}
+
+	server := mgr.GetWebhookServer()
+	server.Register(MutatingWebhookPath, &webhook.Admission{Handler: admission.HandlerFunc(w.handleDefault)})
+	server.Register(ValidatingWebhookPath, &webhook.Admission{Handler: admission.HandlerFunc(w.handleValidate)})
+	return nil
+}
+
+// +kubebuilder:webhook:path=/mutate-alpha-alaska-rudeboy-io-v1-repo,mutating=true,failurePolicy=fail,groups=alpha.alaska.rudeboy.io,resources=repos,verbs=create;update,versions=v1,name=mrepo.kb.io
+
+func (w *RepoWebhook) handleDefault(ctx context.Context, req admission.Request) admission.Response {
+	repo := &Repo{}
+	if err := w.decoder.Decode(req, repo); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	w.Default(ctx, repo)
+
+	marshalled, err := json.Marshal(repo)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshalled)
+}
+
+// Default sets spec.branch to the repository's default branch,
+// spec.configPath to alaska.yaml and spec.concurrencyPolicy to allow
+func (w *RepoWebhook) Default(ctx context.Context, r *Repo) {
+	if r.Spec.ConfigPath == "" {
+		r.Spec.ConfigPath = DefaultConfigPath
+	}
+
+	if r.Spec.ConcurrencyPolicy == "" {
+		r.Spec.ConcurrencyPolicy = ConcurrencyAllow
+	}
+
+	if r.Spec.Branch != "" {
+		return
+	}
+
+	r.Spec.Branch = FallbackBranch
+	if w.Lookup == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	branch, err := w.Lookup.DefaultBranch(ctx, r)
+	if err != nil {
+		repolog.Info("unable to look up default branch", "name", r.GetName(), "url", r.Spec.URL, "error", err.Error())
+		return
+	}
+
+	r.Spec.Branch = branch
+}
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-alpha-alaska-rudeboy-io-v1-repo,mutating=false,failurePolicy=fail,groups=alpha.alaska.rudeboy.io,resources=repos,versions=v1,name=vrepo.kb.io
+
+func (w *RepoWebhook) handleValidate(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation == v1beta1.Delete {
+		return admission.Allowed("")
+	}
+
+	repo := &Repo{}
+	if err := w.decoder.Decode(req, repo); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	var old *Repo
+	if req.Operation == v1beta1.Update {
+		old = &Repo{}
+		if err := w.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+	}
+
+	err := w.Validate(ctx, repo, old)
+	if err == nil {
+		return admission.Allowed("")
+	}
+
+	// keep the field errors, so clients see them as with any invalid object
+	if status, ok := err.(apierrors.APIStatus); ok {
+		result := status.Status()
+		return admission.Response{AdmissionResponse: v1beta1.AdmissionResponse{Allowed: false, Result: &result}}
+	}
+
+	return admission.Denied(err.Error())
+}
+
+// Validate checks the Repo's URL, branch, revision and cluster. On an update,
+// old is the Repo before it; the cluster is only looked up again if it
+// changed, so a Repo whose cluster was deleted can still be suspended or
+// unpinned.
+func (w *RepoWebhook) Validate(ctx context.Context, r *Repo, old *Repo) error {
+	var errs field.ErrorList
+	spec := field.NewPath("spec")
+
+	if r.Spec.URL == "" {
+		errs = append(errs, field.Required(spec.Child("url"), ""))
+	} else if w.Lookup != nil {
+		if err := w.Lookup.ValidateURL(r); err != nil {
+			errs = append(errs, field.Invalid(spec.Child("url"), r.Spec.URL, err.Error()))
+		}
+	}
+
+	if r.Spec.Branch == "" {
+		errs = append(errs, field.Required(spec.Child("branch"), ""))
+	}
+
+	if r.Spec.Revision != "" && !IsCommitSHA(r.Spec.Revision) {
+		errs = append(errs, field.Invalid(spec.Child("revision"), r.Spec.Revision, "must be a commit SHA of 7 to 40 hex characters"))
+	}
+
+	if r.Spec.CredentialsRef != nil && r.Spec.CredentialsRef.Name == "" {
+		errs = append(errs, field.Required(spec.Child("credentialsRef", "name"), ""))
+	}
+
+	if r.Spec.Cluster == "" {
+		errs = append(errs, field.Required(spec.Child("cluster"), ""))
+	} else if old == nil || old.Spec.Cluster != r.Spec.Cluster {
+		if err := w.validateCluster(ctx, r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return apierrors.NewInvalid(GroupVersion.WithKind("Repo").GroupKind(), r.GetName(), errs)
+}
+
+// validateCluster checks that spec.cluster names a cluster PipelineResource
+// in the Repo's namespace
+func (w *RepoWebhook) validateCluster(ctx context.Context, r *Repo) *field.Error {
+	path := field.NewPath("spec", "cluster")
+	if w.Reader == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+
+	resource := &tektonv1.PipelineResource{}
+	err := w.Reader.Get(ctx, types.NamespacedName{Namespace: r.GetNamespace(), Name: r.Spec.Cluster}, resource)
+	switch {
+	case apierrors.IsNotFound(err):
+		return field.NotFound(path, r.Spec.Cluster)
+	case err != nil:
+		return field.InternalError(path, err)
+	case resource.Spec.Type != tektonv1.PipelineResourceTypeCluster:
+		return field.Invalid(path, r.Spec.Cluster, "PipelineResource is of type "+string(resource.Spec.Type)+", not cluster")
+	}
+
+	return nil
+}
@@ -0,0 +1,197 @@
+package v1
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+var (
+	supportedStrategies = []string{string(StrategyDefault), string(StrategySequential)}
+	supportedExecutors  = []string{string(ExecutorDefault), string(ExecutorHelm), string(ExecutorKustomize), string(ExecutorGeneric)}
+	supportedTools      = []string{string(ToolKubectl), string(ToolHelm), string(ToolKustomize)}
+
+	// maxReleaseLength is the longest release name helm 3 accepts
+	maxReleaseLength = 53
+
+	// supportedLoadRestrictors are kustomize build's --load_restrictor values
+	supportedLoadRestrictors = []string{"rootOnly", "none"}
+)
+
+// Validate checks the config for mistakes that don't depend on the
+// controller's settings: unknown strategies, executors and tools, manifests
+// without a path, paths leaving the repo, invalid namespaces and helm
+// release names, and helm releases deployed twice.
+// Errors are reported against the fields users write in alaska.yaml.
+func (c *Config) Validate() field.ErrorList {
+	errs := field.ErrorList{}
+
+	errs = append(errs, validateStrategy(c.Strategy, field.NewPath("strategy"))...)
+	errs = append(errs, validateToolNames(c.Tools, field.NewPath("tools"))...)
+	errs = append(errs, validateNamespace(c.Namespace, field.NewPath("namespace"))...)
+
+	for i, manifest := range c.Manifests {
+		errs = append(errs, manifest.validate(field.NewPath("manifests").Index(i))...)
+	}
+
+	for s, stage := range c.Stages {
+		stagePath := field.NewPath("stages").Index(s)
+		errs = append(errs, validateStrategy(stage.Strategy, stagePath.Child("strategy"))...)
+		for i, manifest := range stage.Manifests {
+			errs = append(errs, manifest.validate(stagePath.Child("manifests").Index(i))...)
+		}
+	}
+
+	errs = append(errs, c.validateReleases()...)
+
+	return errs
+}
+
+// validate checks a single manifest, found at fldPath
+func (mo *ManifestOptions) validate(fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+
+	if mo.Type != "" && !containsString(supportedExecutors, string(mo.Type)) {
+		errs = append(errs, field.NotSupported(fldPath.Child("type"), mo.Type, supportedExecutors))
+	}
+
+	// a remote chart is the only manifest that needs nothing from the repo
+	if mo.Path == "" && !(mo.Type == ExecutorHelm && mo.Chart != "") {
+		if mo.Type == ExecutorHelm {
+			errs = append(errs, field.Required(fldPath.Child("path"), "helm manifests need a path or a chart"))
+		} else {
+			errs = append(errs, field.Required(fldPath.Child("path"), ""))
+		}
+	}
+
+	if mo.Path != "" {
+		errs = append(errs, validateRepoPath(mo.Path, fldPath.Child("path"))...)
+	}
+
+	errs = append(errs, validateToolNames(mo.Tools, fldPath.Child("tools"))...)
+	errs = append(errs, validateNamespace(mo.Namespace, fldPath.Child("namespace"))...)
+
+	if mo.Type == ExecutorHelm {
+		errs = append(errs, validateRelease(mo.release(), fldPath.Child("release"))...)
+
+		for i, file := range mo.ValuesFiles {
+			errs = append(errs, validateRepoPath(file, fldPath.Child("valuesFiles").Index(i))...)
+		}
+
+		if mo.Chart == "" && mo.RepoURL != "" {
+			errs = append(errs, field.Forbidden(fldPath.Child("repoURL"), "repoURL needs a chart"))
+		}
+
+		if mo.Chart == "" && mo.Version != "" {
+			errs = append(errs, field.Forbidden(fldPath.Child("version"), "version needs a chart"))
+		}
+	}
+
+	if mo.LoadRestrictor != "" && !containsString(supportedLoadRestrictors, mo.LoadRestrictor) {
+		errs = append(errs, field.NotSupported(fldPath.Child("loadRestrictor"), mo.LoadRestrictor, supportedLoadRestrictors))
+	}
+
+	if mo.Type == ExecutorGeneric && mo.Image == "" {
+		errs = append(errs, field.Required(fldPath.Child("image"), "generic manifests run an image"))
+	}
+
+	return errs
+}
+
+// validateReleases checks that no two helm manifests install the same
+// release in the same namespace, where one would overwrite the other
+func (c *Config) validateReleases() field.ErrorList {
+	errs := field.ErrorList{}
+	seen := map[string]bool{}
+
+	check := func(manifest *ManifestOptions, fldPath *field.Path) {
+		if manifest.Type != ExecutorHelm {
+			return
+		}
+
+		key := c.namespace(manifest) + "/" + manifest.release()
+		if seen[key] {
+			errs = append(errs, field.Duplicate(fldPath.Child("release"), manifest.release()))
+		}
+		seen[key] = true
+	}
+
+	for i, manifest := range c.Manifests {
+		check(manifest, field.NewPath("manifests").Index(i))
+	}
+
+	for s, stage := range c.Stages {
+		for i, manifest := range stage.Manifests {
+			check(manifest, field.NewPath("stages").Index(s).Child("manifests").Index(i))
+		}
+	}
+
+	return errs
+}
+
+func validateStrategy(strategy Strategy, fldPath *field.Path) field.ErrorList {
+	if strategy == "" || containsString(supportedStrategies, string(strategy)) {
+		return nil
+	}
+
+	return field.ErrorList{field.NotSupported(fldPath, strategy, supportedStrategies)}
+}
+
+func validateToolNames(tools Tools, fldPath *field.Path) field.ErrorList {
+	names := []string{}
+	for tool := range tools {
+		names = append(names, string(tool))
+	}
+	sort.Strings(names)
+
+	errs := field.ErrorList{}
+	for _, tool := range names {
+		if !containsString(supportedTools, tool) {
+			errs = append(errs, field.NotSupported(fldPath.Key(tool), tool, supportedTools))
+		}
+	}
+
+	return errs
+}
+
+// validateNamespace checks that namespace, if set, is a valid namespace name
+func validateNamespace(namespace string, fldPath *field.Path) field.ErrorList {
+	if namespace == "" {
+		return nil
+	}
+
+	errs := field.ErrorList{}
+	for _, msg := range validation.IsDNS1123Label(namespace) {
+		errs = append(errs, field.Invalid(fldPath, namespace, msg))
+	}
+
+	return errs
+}
+
+// validateRelease checks that release is a name helm 3 accepts
+func validateRelease(release string, fldPath *field.Path) field.ErrorList {
+	errs := field.ErrorList{}
+	for _, msg := range validation.IsDNS1123Subdomain(release) {
+		errs = append(errs, field.Invalid(fldPath, release, msg))
+	}
+
+	if len(release) > maxReleaseLength {
+		errs = append(errs, field.TooLong(fldPath, release, maxReleaseLength))
+	}
+
+	return errs
+}
+
+// validateRepoPath checks that p is relative to the repo root and stays
+// within it once cleaned
+func validateRepoPath(p string, fldPath *field.Path) field.ErrorList {
+	clean := path.Clean(p)
+	if path.IsAbs(p) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return field.ErrorList{field.Invalid(fldPath, p, "must be a path within the repo")}
+	}
+
+	return nil
+}
@@ -0,0 +1,113 @@
+package v1
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Config.Validate", func() {
+	var cfg *Config
+
+	BeforeEach(func() {
+		cfg = &Config{
+			Manifests: []*ManifestOptions{
+				{Path: "crds/"},
+				{Path: "charts/nginx", Type: ExecutorHelm},
+			},
+		}
+	})
+
+	messages := func() []string {
+		msgs := []string{}
+		for _, err := range cfg.Validate() {
+			msgs = append(msgs, err.Error())
+		}
+		return msgs
+	}
+
+	It("should accept a valid config", func() {
+		Expect(cfg.Validate()).To(BeEmpty())
+	})
+
+	It("should reject unknown strategies and executors", func() {
+		cfg.Strategy = "sequental"
+		cfg.Manifests[0].Type = "kubecfg"
+		Expect(messages()).To(Equal([]string{
+			`strategy: Unsupported value: "sequental": supported values: "parallel", "sequential"`,
+			`manifests[0].type: Unsupported value: "kubecfg": supported values: "kubectl", "helm", "kustomize", "generic"`,
+		}))
+	})
+
+	It("should reject empty paths", func() {
+		cfg.Manifests[0].Path = ""
+		Expect(messages()).To(Equal([]string{"manifests[0].path: Required value"}))
+	})
+
+	It("should allow a remote chart without a path", func() {
+		cfg.Manifests[1].Path = ""
+		cfg.Manifests[1].Chart = "stable/nginx-ingress"
+		Expect(cfg.Validate()).To(BeEmpty())
+	})
+
+	It("should reject paths leaving the repo", func() {
+		cfg.Manifests[0].Path = "crds/../../secrets"
+		cfg.Manifests[1].ValuesFiles = []string{"/etc/passwd"}
+		Expect(messages()).To(Equal([]string{
+			`manifests[0].path: Invalid value: "crds/../../secrets": must be a path within the repo`,
+			`manifests[1].valuesFiles[0]: Invalid value: "/etc/passwd": must be a path within the repo`,
+		}))
+	})
+
+	It("should reject namespaces that aren't DNS-1123 labels", func() {
+		cfg.Namespace = "apps"
+		cfg.Manifests[1].Namespace = "$(id)"
+		Expect(messages()).To(HaveLen(1))
+		Expect(messages()[0]).To(HavePrefix(`manifests[1].namespace: Invalid value: "$(id)": a DNS-1123 label must consist of`))
+
+		cfg.Namespace = "Apps"
+		cfg.Manifests[1].Namespace = "monitoring"
+		Expect(messages()).To(HaveLen(1))
+		Expect(messages()[0]).To(HavePrefix(`namespace: Invalid value: "Apps"`))
+	})
+
+	It("should reject unknown load restrictors", func() {
+		cfg.Manifests[0].Type = ExecutorKustomize
+		cfg.Manifests[0].LoadRestrictor = "none; rm -rf /"
+		Expect(messages()).To(Equal([]string{
+			`manifests[0].loadRestrictor: Unsupported value: "none; rm -rf /": supported values: "rootOnly", "none"`,
+		}))
+
+		cfg.Manifests[0].LoadRestrictor = "none"
+		Expect(cfg.Validate()).To(BeEmpty())
+	})
+
+	It("should reject helm release names helm doesn't accept", func() {
+		cfg.Manifests[1].Release = "nginx; id"
+		Expect(messages()).To(HaveLen(1))
+		Expect(messages()[0]).To(HavePrefix(`manifests[1].release: Invalid value: "nginx; id": a DNS-1123 subdomain must consist of`))
+
+		cfg.Manifests[1].Release = ""
+		cfg.Manifests[1].Path = "charts/Nginx_Ingress"
+		Expect(messages()).To(HaveLen(1))
+		Expect(messages()[0]).To(HavePrefix(`manifests[1].release: Invalid value: "Nginx_Ingress"`))
+
+		cfg.Manifests[1].Release = strings.Repeat("a", 54)
+		Expect(messages()).To(Equal([]string{"manifests[1].release: Too long: must have at most 53 characters"}))
+	})
+
+	It("should reject duplicate helm releases in a namespace", func() {
+		cfg.Manifests = append(cfg.Manifests, &ManifestOptions{Path: "other/nginx", Type: ExecutorHelm})
+		Expect(messages()).To(Equal([]string{`manifests[2].release: Duplicate value: "nginx"`}))
+
+		cfg.Manifests[2].Namespace = "ingress"
+		Expect(cfg.Validate()).To(BeEmpty())
+	})
+
+	It("should report errors in stages against their stage", func() {
+		cfg.Stages = []*Stage{{Name: "apps", Manifests: []*ManifestOptions{{Type: ExecutorGeneric, Path: "migrations"}}}}
+		cfg.Manifests = nil
+		Expect(messages()).To(Equal([]string{"stages[0].manifests[0].image: Required value: generic manifests run an image"}))
+	})
+})
@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"github.com/spf13/cobra"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 var createCmd = &cobra.Command{
@@ -10,6 +13,22 @@ var createCmd = &cobra.Command{
 	Long:  "create an Alaska resource, such as a Repo or a Kubernetes cluster Secret",
 }
 
+// kubeClient returns a client for the cluster in kubeconfig. The create
+// commands talk to two clusters, so they don't use the global --kubeconfig.
+func kubeClient(kubeconfig string) (*rest.Config, client.Client, error) {
+	cfg, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cfg, c, nil
+}
+
 func init() {
 	rootCmd.AddCommand(createCmd)
 }
@@ -0,0 +1,294 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+	"github.com/rudoi/alaska/pkg/git"
+	"github.com/spf13/cobra"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/homedir"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+type RepoOptions struct {
+	Name       string
+	Namespace  string
+	URL        string
+	Branch     string
+	Cluster    string
+	Provider   string
+	ConfigPath string
+
+	// TokenFromEnv names the environment variable holding an access token,
+	// Username is sent with it for basic auth
+	TokenFromEnv string
+	Username     string
+
+	SSHKey     string
+	KnownHosts string
+
+	// AlaskaKubeconfig is the cluster the Repo is created in. If
+	// TargetKubeconfig is set, credentials for it are created as the Repo's
+	// cluster.
+	AlaskaKubeconfig string
+	TargetKubeconfig string
+	TargetNamespace  string
+}
+
+var rpo = &RepoOptions{}
+
+var createRepoCmd = &cobra.Command{
+	Use:   "repo [name]",
+	Short: "create a Repo with its credentials",
+	Long: "create a Repo, the Secret holding its git credentials and optionally credentials for the cluster it deploys to, " +
+		"after checking that the branch exists and has a config file. The name defaults to the repository's.",
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 0 {
+			rpo.Name = args[0]
+		}
+
+		if err := RunRepoCreate(rpo); err != nil {
+			klog.Exit(err)
+		}
+	},
+}
+
+// RunRepoCreate creates the Repo and its credentials. If a step fails,
+// everything created before it is deleted again.
+func RunRepoCreate(rpo *RepoOptions) (err error) {
+	ctx := context.Background()
+
+	repo := rpo.repo()
+	if errs := validation.IsDNS1123Subdomain(repo.GetName()); len(errs) > 0 {
+		return fmt.Errorf("invalid repo name %q, pass a name as the first argument: %s", repo.GetName(), strings.Join(errs, ", "))
+	}
+
+	creds, err := rpo.credentials()
+	if err != nil {
+		return err
+	}
+
+	if err := checkRepo(ctx, repo, creds); err != nil {
+		return err
+	}
+
+	_, c, err := kubeClient(rpo.AlaskaKubeconfig)
+	if err != nil {
+		return err
+	}
+
+	if rpo.TargetKubeconfig == "" {
+		// without a cluster to create credentials for, they must exist
+		resource := &tektonv1.PipelineResource{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: repo.GetNamespace(), Name: repo.Spec.Cluster}, resource); err != nil {
+			return fmt.Errorf("unable to get cluster %q, use --target-kubeconfig to create it: %v", repo.Spec.Cluster, err)
+		}
+	}
+
+	cleanups := []func(){}
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(cleanups) - 1; i >= 0; i-- {
+			cleanups[i]()
+		}
+	}()
+
+	var secret *corev1.Secret
+	if creds != nil {
+		secret = credentialsSecret(repo, creds)
+		if err := c.Create(ctx, secret); err != nil {
+			return err
+		}
+		cleanups = append(cleanups, deleteFunc(ctx, c, secret, "secret"))
+		repo.Spec.CredentialsRef = &corev1.LocalObjectReference{Name: secret.GetName()}
+		fmt.Printf("secret/%s created\n", secret.GetName())
+	}
+
+	if rpo.TargetKubeconfig != "" {
+		cleanup, err := createServiceAccount(ctx, &ServiceAccountOptions{
+			AlaskaKubeconfig: rpo.AlaskaKubeconfig,
+			AlaskaNamespace:  repo.GetNamespace(),
+			Name:             repo.Spec.Cluster,
+			TargetKubeconfig: rpo.TargetKubeconfig,
+			TargetNamespace:  rpo.TargetNamespace,
+		})
+		if err != nil {
+			return err
+		}
+		cleanups = append(cleanups, cleanup)
+		fmt.Printf("pipelineresource/%s created\n", repo.Spec.Cluster)
+	}
+
+	if err := c.Create(ctx, repo); err != nil {
+		return err
+	}
+	cleanups = append(cleanups, deleteFunc(ctx, c, repo, "repo"))
+	fmt.Printf("repo/%s created\n", repo.GetName())
+
+	if secret == nil {
+		return nil
+	}
+
+	// the Secret existed before the Repo, but goes away with it
+	patch := client.MergeFrom(secret.DeepCopyObject())
+	secret.OwnerReferences = append(secret.OwnerReferences, metav1.OwnerReference{
+		APIVersion: alphav1.GroupVersion.String(),
+		Kind:       "Repo",
+		Name:       repo.GetName(),
+		UID:        repo.GetUID(),
+	})
+	return c.Patch(ctx, secret, patch)
+}
+
+// repo returns the Repo the options describe
+func (rpo *RepoOptions) repo() *alphav1.Repo {
+	name := rpo.Name
+	if name == "" {
+		name = strings.ToLower(strings.TrimSuffix(path.Base(strings.TrimRight(rpo.URL, "/")), ".git"))
+	}
+
+	return &alphav1.Repo{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: rpo.Namespace,
+		},
+		Spec: alphav1.RepoSpec{
+			URL:        rpo.URL,
+			Branch:     rpo.Branch,
+			Cluster:    rpo.Cluster,
+			Provider:   alphav1.GitProvider(rpo.Provider),
+			ConfigPath: rpo.ConfigPath,
+		},
+	}
+}
+
+// credentials returns the git credentials the options point at, or nil
+func (rpo *RepoOptions) credentials() (*git.Credentials, error) {
+	switch {
+	case rpo.TokenFromEnv != "" && rpo.SSHKey != "":
+		return nil, fmt.Errorf("use either --token-from-env or --ssh-key, not both")
+	case rpo.TokenFromEnv != "":
+		token := os.Getenv(rpo.TokenFromEnv)
+		if token == "" {
+			return nil, fmt.Errorf("environment variable %s is empty", rpo.TokenFromEnv)
+		}
+		if rpo.Username != "" {
+			return &git.Credentials{Username: rpo.Username, Password: token}, nil
+		}
+		return &git.Credentials{Token: token}, nil
+	case rpo.SSHKey != "":
+		key, err := ioutil.ReadFile(rpo.SSHKey)
+		if err != nil {
+			return nil, err
+		}
+		creds := &git.Credentials{SSHPrivateKey: key}
+		if rpo.KnownHosts != "" {
+			if creds.KnownHosts, err = ioutil.ReadFile(rpo.KnownHosts); err != nil {
+				return nil, err
+			}
+		}
+		return creds, nil
+	default:
+		return nil, nil
+	}
+}
+
+// checkRepo checks that the Repo's branch exists and has a config file,
+// defaulting the branch to the repository's default branch
+func checkRepo(ctx context.Context, repo *alphav1.Repo, creds *git.Credentials) error {
+	factory := &git.Factory{}
+	if err := factory.Validate(repo); err != nil {
+		return err
+	}
+
+	provider, err := factory.ForRepo(repo, creds)
+	if err != nil {
+		return err
+	}
+
+	if repo.Spec.Branch == "" {
+		if repo.Spec.Branch, err = provider.DefaultBranch(ctx); err != nil {
+			return fmt.Errorf("unable to look up the default branch of %s, use --branch: %v", repo.Spec.URL, err)
+		}
+	}
+
+	head, err := provider.Head(ctx, repo.Spec.Branch)
+	if err != nil {
+		return fmt.Errorf("unable to find branch %q: %v", repo.Spec.Branch, err)
+	}
+
+	if _, err := provider.ReadFile(ctx, head, repo.GetConfigPath()); err != nil {
+		return fmt.Errorf("unable to read %s at %s: %v", repo.GetConfigPath(), head[:7], err)
+	}
+
+	return nil
+}
+
+// credentialsSecret returns the Secret a Repo's credentialsRef points at
+func credentialsSecret(repo *alphav1.Repo, creds *git.Credentials) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      repo.GetName() + "-credentials",
+			Namespace: repo.GetNamespace(),
+		},
+	}
+
+	switch {
+	case creds.IsSSH():
+		secret.Type = corev1.SecretTypeSSHAuth
+		secret.Data = map[string][]byte{corev1.SSHAuthPrivateKey: creds.SSHPrivateKey}
+		if len(creds.KnownHosts) > 0 {
+			secret.Data[git.SecretKnownHostsKey] = creds.KnownHosts
+		}
+	case creds.Username != "":
+		secret.Type = corev1.SecretTypeBasicAuth
+		secret.Data = map[string][]byte{
+			corev1.BasicAuthUsernameKey: []byte(creds.Username),
+			corev1.BasicAuthPasswordKey: []byte(creds.Password),
+		}
+	default:
+		secret.Type = corev1.SecretTypeOpaque
+		secret.Data = map[string][]byte{git.SecretTokenKey: []byte(creds.Token)}
+	}
+
+	return secret
+}
+
+func init() {
+	home := homedir.HomeDir()
+	// required
+	createRepoCmd.Flags().StringVarP(&rpo.URL, "url", "", "", "url of the git repository - required")
+	_ = createRepoCmd.MarkFlagRequired("url")
+	createRepoCmd.Flags().StringVarP(&rpo.Cluster, "cluster", "", "", "name of the cluster PipelineResource to deploy to - required")
+	_ = createRepoCmd.MarkFlagRequired("cluster")
+
+	// optional
+	createRepoCmd.Flags().StringVarP(&rpo.Namespace, "namespace", "", "default", "namespace for the Repo")
+	createRepoCmd.Flags().StringVarP(&rpo.Branch, "branch", "", "", "branch to deploy - defaults to the repository's default branch")
+	createRepoCmd.Flags().StringVarP(&rpo.Provider, "provider", "", "", "git provider, github, gitlab or git - inferred from the url if unset")
+	createRepoCmd.Flags().StringVarP(&rpo.ConfigPath, "config-path", "", "", "path of the config file within the repo - defaults to alaska.yaml")
+	createRepoCmd.Flags().StringVarP(&rpo.TokenFromEnv, "token-from-env", "", "", "environment variable holding an access token for the repository")
+	createRepoCmd.Flags().StringVarP(&rpo.Username, "username", "", "", "username to send with the token, for basic auth")
+	createRepoCmd.Flags().StringVarP(&rpo.SSHKey, "ssh-key", "", "", "private key file for an SSH url")
+	createRepoCmd.Flags().StringVarP(&rpo.KnownHosts, "known-hosts", "", "", "known_hosts file for --ssh-key")
+	createRepoCmd.Flags().StringVarP(&rpo.AlaskaKubeconfig, "alaska-kubeconfig", "", filepath.Join(home, ".kube", "config"), "kubeconfig of the cluster Alaska runs in")
+	createRepoCmd.Flags().StringVarP(&rpo.TargetKubeconfig, "target-kubeconfig", "", "", "kubeconfig of the cluster to deploy to, to create a serviceaccount and the --cluster PipelineResource for it")
+	createRepoCmd.Flags().StringVarP(&rpo.TargetNamespace, "target-namespace", "", "default", "namespace for the serviceaccount in the target cluster")
+
+	createCmd.AddCommand(createRepoCmd)
+}
@@ -3,17 +3,19 @@ package cmd
 import (
 	"context"
 	"encoding/base64"
-	"flag"
+	"fmt"
+	"os"
 	"path/filepath"
 	"time"
 
 	"github.com/spf13/cobra"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/util/homedir"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/client/config"
 
 	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
 	"k8s.io/klog"
@@ -41,30 +43,25 @@ var createServiceAccountCmd = &cobra.Command{
 }
 
 func RunServiceAccountCreate(sao *ServiceAccountOptions) error {
-	_ = flag.Set("kubeconfig", sao.TargetKubeconfig)
-	targetCfg, err := config.GetConfig()
-	if err != nil {
-		return err
-	}
-
-	targetClient, err := client.New(targetCfg, client.Options{Scheme: scheme})
-	if err != nil {
-		return nil
-	}
+	_, err := createServiceAccount(context.Background(), sao)
+	return err
+}
 
-	_ = flag.Set("kubeconfig", sao.AlaskaKubeconfig)
-	cfg, err := config.GetConfig()
+// createServiceAccount creates the ServiceAccount in the target cluster and
+// the PipelineResource with its credentials. If that fails, the
+// ServiceAccount is deleted again. On success it returns a function deleting
+// both, for callers that fail later.
+func createServiceAccount(ctx context.Context, sao *ServiceAccountOptions) (func(), error) {
+	targetCfg, targetClient, err := kubeClient(sao.TargetKubeconfig)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	client, err := client.New(cfg, client.Options{Scheme: scheme})
+	_, client, err := kubeClient(sao.AlaskaKubeconfig)
 	if err != nil {
-		return nil
+		return nil, err
 	}
 
-	ctx := context.Background()
-
 	sa := &corev1.ServiceAccount{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      sao.Name,
@@ -72,26 +69,41 @@ func RunServiceAccountCreate(sao *ServiceAccountOptions) error {
 		},
 	}
 	if err := targetClient.Create(ctx, sa); err != nil {
-		return err
+		return nil, err
 	}
+	deleteSA := deleteFunc(ctx, targetClient, sa, "serviceaccount")
 
 	time.Sleep(5 * time.Second)
 
 	if err := targetClient.Get(ctx, types.NamespacedName{Namespace: sao.TargetNamespace, Name: sao.Name}, sa); err != nil {
-		return err
+		deleteSA()
+		return nil, err
+	}
+
+	if len(sa.Secrets) == 0 {
+		deleteSA()
+		return nil, fmt.Errorf("serviceaccount/%s has no token secret", sao.Name)
 	}
 
 	secret := &corev1.Secret{}
 	if err := targetClient.Get(ctx, types.NamespacedName{Namespace: sao.TargetNamespace, Name: sa.Secrets[0].Name}, secret); err != nil {
-		return err
+		deleteSA()
+		return nil, err
 	}
 
 	cadata := base64.StdEncoding.EncodeToString(secret.Data["ca.crt"])
 
+	// the PipelineResource goes next to the ServiceAccount unless it's
+	// asked for elsewhere, e.g. next to the Repo akctl create repo creates
+	namespace := sao.AlaskaNamespace
+	if namespace == "" {
+		namespace = sao.TargetNamespace
+	}
+
 	resource := &tektonv1.PipelineResource{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      sao.Name,
-			Namespace: sao.TargetNamespace,
+			Namespace: namespace,
 		},
 		Spec: tektonv1.PipelineResourceSpec{
 			Type: tektonv1.PipelineResourceTypeCluster,
@@ -120,7 +132,30 @@ func RunServiceAccountCreate(sao *ServiceAccountOptions) error {
 		},
 	}
 
-	return client.Create(ctx, resource)
+	if err := client.Create(ctx, resource); err != nil {
+		deleteSA()
+		return nil, err
+	}
+	deleteResource := deleteFunc(ctx, client, resource, "pipelineresource")
+
+	return func() {
+		deleteResource()
+		deleteSA()
+	}, nil
+}
+
+// deleteFunc returns a function deleting obj, which was just created,
+// because a later step failed. Errors are reported so it can be deleted by
+// hand.
+func deleteFunc(ctx context.Context, c client.Client, obj runtime.Object, kind string) func() {
+	name := obj.(metav1.Object).GetName()
+	return func() {
+		if err := c.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			fmt.Fprintf(os.Stderr, "unable to delete %s/%s, delete it by hand: %v\n", kind, name, err)
+			return
+		}
+		fmt.Printf("%s/%s deleted\n", kind, name)
+	}
 }
 
 func init() {
@@ -136,6 +171,7 @@ func init() {
 	// optional
 	createServiceAccountCmd.Flags().StringVarP(&sao.AlaskaKubeconfig, "alaska-kubeconfig", "", filepath.Join(home, ".kube", "config"), "kubeconfig to use for creating serviceaccount")
 	createServiceAccountCmd.Flags().StringVarP(&sao.TargetNamespace, "alaska-namespace", "", "default", "namespace for new serviceaccount")
+	createServiceAccountCmd.Flags().StringVarP(&sao.AlaskaNamespace, "resource-namespace", "", "", "namespace for the cluster PipelineResource, where the Repos using it are - defaults to the serviceaccount's")
 
 	createCmd.AddCommand(createServiceAccountCmd)
 }
@@ -0,0 +1,350 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+	"github.com/spf13/cobra"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/klog"
+	knative "knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+	"sigs.k8s.io/yaml"
+)
+
+// describeEventLimit is the number of recent Events describe shows
+const describeEventLimit = 10
+
+type DescribeOptions struct {
+	Namespace string
+	Output    string
+}
+
+var describeCmd = &cobra.Command{
+	Use:   "describe",
+	Short: "show details of an Alaska resource",
+}
+
+var dso = &DescribeOptions{}
+var describeRepoCmd = &cobra.Command{
+	Use:     "repo <name>",
+	Aliases: []string{"repos", "rp"},
+	Short:   "show details of a Repo",
+	Long:    "show a Repo's config, pipeline tasks, run history with the outcome of each TaskRun and recent Events",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := RunDescribeRepo(args[0], dso); err != nil {
+			klog.Exit(err)
+		}
+	},
+}
+
+// repoDescription is everything describe shows about a Repo
+type repoDescription struct {
+	repo     *alphav1.Repo
+	pipeline *tektonv1.Pipeline
+
+	runs   []describedRun
+	events []corev1.Event
+}
+
+// describedRun is one of the Repo's Status.Runs with its PipelineRun, which
+// is nil if it's gone or was never recorded
+type describedRun struct {
+	status      *alphav1.PipelineStatus
+	pipelineRun *tektonv1.PipelineRun
+}
+
+func RunDescribeRepo(name string, dso *DescribeOptions) error {
+	switch dso.Output {
+	case "", "yaml", "json":
+	default:
+		return fmt.Errorf("unknown output format %q, expected yaml or json", dso.Output)
+	}
+
+	ctx := context.Background()
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+
+	d, err := getRepoDescription(ctx, c, types.NamespacedName{Namespace: dso.Namespace, Name: name})
+	if err != nil {
+		return err
+	}
+
+	if dso.Output != "" {
+		return printObjects(d.objects(), dso.Output)
+	}
+
+	return d.print(os.Stdout)
+}
+
+func getRepoDescription(ctx context.Context, c client.Client, key types.NamespacedName) (*repoDescription, error) {
+	d := &repoDescription{repo: &alphav1.Repo{}}
+	if err := c.Get(ctx, key, d.repo); err != nil {
+		return nil, err
+	}
+
+	pipeline := &tektonv1.Pipeline{}
+	if err := c.Get(ctx, key, pipeline); err == nil {
+		d.pipeline = pipeline
+	} else if !apierrors.IsNotFound(err) {
+		return nil, err
+	}
+
+	// Events about the Repo and its PipelineRuns
+	involved := map[types.UID]bool{d.repo.GetUID(): true}
+	for _, run := range d.repo.Status.Runs {
+		if run == nil {
+			continue
+		}
+
+		if run.Ref == nil {
+			d.runs = append(d.runs, describedRun{status: run})
+			continue
+		}
+
+		pipelineRun := &tektonv1.PipelineRun{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: run.Ref.Namespace, Name: run.Ref.Name}, pipelineRun)
+		switch {
+		case apierrors.IsNotFound(err):
+			pipelineRun = nil
+		case err != nil:
+			return nil, err
+		default:
+			involved[pipelineRun.GetUID()] = true
+		}
+		d.runs = append(d.runs, describedRun{status: run, pipelineRun: pipelineRun})
+	}
+
+	events := &corev1.EventList{}
+	if err := c.List(ctx, events, client.InNamespace(key.Namespace)); err != nil {
+		return nil, err
+	}
+	for _, event := range events.Items {
+		if involved[event.InvolvedObject.UID] {
+			d.events = append(d.events, event)
+		}
+	}
+	sort.Slice(d.events, func(i, j int) bool {
+		return eventTime(d.events[i]).Before(eventTime(d.events[j]))
+	})
+	if len(d.events) > describeEventLimit {
+		d.events = d.events[len(d.events)-describeEventLimit:]
+	}
+
+	return d, nil
+}
+
+// objects returns everything described, for printing as YAML or JSON
+func (d *repoDescription) objects() []runtime.Object {
+	d.repo.TypeMeta = metav1.TypeMeta{APIVersion: alphav1.GroupVersion.String(), Kind: "Repo"}
+	objects := []runtime.Object{d.repo}
+
+	if d.pipeline != nil {
+		d.pipeline.TypeMeta = metav1.TypeMeta{APIVersion: tektonv1.SchemeGroupVersion.String(), Kind: "Pipeline"}
+		objects = append(objects, d.pipeline)
+	}
+
+	for _, run := range d.runs {
+		if run.pipelineRun != nil {
+			run.pipelineRun.TypeMeta = metav1.TypeMeta{APIVersion: tektonv1.SchemeGroupVersion.String(), Kind: "PipelineRun"}
+			objects = append(objects, run.pipelineRun)
+		}
+	}
+
+	for i := range d.events {
+		d.events[i].TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Event"}
+		objects = append(objects, &d.events[i])
+	}
+
+	return objects
+}
+
+func (d *repoDescription) print(out io.Writer) error {
+	repo := d.repo
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Name:\t%s\n", repo.Name)
+	fmt.Fprintf(w, "Namespace:\t%s\n", repo.Namespace)
+	fmt.Fprintf(w, "URL:\t%s\n", repo.Spec.URL)
+	fmt.Fprintf(w, "Branch:\t%s\n", repo.Spec.Branch)
+	fmt.Fprintf(w, "Cluster:\t%s\n", repo.Spec.Cluster)
+	fmt.Fprintf(w, "Provider:\t%s\n", valueOrNone(string(repo.Spec.Provider)))
+	fmt.Fprintf(w, "Config Path:\t%s\n", repo.GetConfigPath())
+	fmt.Fprintf(w, "Concurrency Policy:\t%s\n", repo.GetConcurrencyPolicy())
+	if repo.Spec.CredentialsRef != nil {
+		fmt.Fprintf(w, "Credentials:\t%s\n", repo.Spec.CredentialsRef.Name)
+	}
+	if repo.Spec.Revision != "" {
+		fmt.Fprintf(w, "Revision:\t%s (pinned by %s: %s)\n", repo.Spec.Revision,
+			valueOrNone(repo.Annotations[alphav1.AnnotationPinnedBy]), valueOrNone(repo.Annotations[alphav1.AnnotationPinReason]))
+	}
+	if condition := repo.Status.GetCondition(alphav1.ConditionSuspended); condition != nil && condition.Status == corev1.ConditionTrue {
+		fmt.Fprintf(w, "Suspended:\t%s\n", condition.Message)
+	}
+	fmt.Fprintf(w, "Commit:\t%s\n", valueOrNone(repo.Status.CommitSHA))
+	if repo.Status.QueuedCommit != "" {
+		fmt.Fprintf(w, "Queued Commit:\t%s\n", repo.Status.QueuedCommit)
+	}
+	if repo.Status.LastSyncTime != nil {
+		fmt.Fprintf(w, "Last Sync:\t%s ago\n", age(*repo.Status.LastSyncTime))
+	}
+	fmt.Fprintf(w, "Age:\t%s\n", age(repo.CreationTimestamp))
+
+	fmt.Fprintln(w, "Conditions:")
+	if len(repo.Status.Conditions) == 0 {
+		fmt.Fprintln(w, "  <none>")
+	} else {
+		fmt.Fprintln(w, "  TYPE\tSTATUS\tREASON\tMESSAGE")
+		for _, condition := range repo.Status.Conditions {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", condition.Type, condition.Status, valueOrNone(condition.Reason), condition.Message)
+		}
+	}
+
+	// the config is printed as is, so flush the table before it
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(out, "Config:")
+	if repo.Status.Config == nil {
+		fmt.Fprintln(out, "  <none>")
+	} else {
+		config, err := yaml.Marshal(repo.Status.Config)
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(strings.TrimRight(string(config), "\n"), "\n") {
+			fmt.Fprintf(out, "  %s\n", line)
+		}
+	}
+
+	fmt.Fprintln(w, "Pipeline Tasks:")
+	if d.pipeline == nil || len(d.pipeline.Spec.Tasks) == 0 {
+		fmt.Fprintln(w, "  <none>")
+	} else {
+		fmt.Fprintln(w, "  NAME\tTASK\tPATH\tRUN AFTER")
+		for _, task := range d.pipeline.Spec.Tasks {
+			path := ""
+			for _, param := range task.Params {
+				if param.Name == "path" {
+					path = param.Value.StringVal
+				}
+			}
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", task.Name, task.TaskRef.Name, valueOrNone(path), valueOrNone(strings.Join(task.RunAfter, ",")))
+		}
+	}
+
+	fmt.Fprintln(w, "Runs:")
+	if len(d.runs) == 0 {
+		fmt.Fprintln(w, "  <none>")
+	}
+	for _, described := range d.runs {
+		run, pipelineRun := described.status, described.pipelineRun
+		switch {
+		case run.Ref == nil:
+			fmt.Fprintf(w, "  <unknown>\t%s\t%s\t(no PipelineRun recorded)\n", valueOrNone(runCommit(repo, run)), valueOrNone(run.Status))
+			continue
+		case pipelineRun == nil:
+			fmt.Fprintf(w, "  %s\t%s\t%s\t(deleted)\n", run.Ref.Name, valueOrNone(runCommit(repo, run)), valueOrNone(run.Status))
+			continue
+		}
+
+		result := runResult(pipelineRun.Status.GetCondition(knative.ConditionSucceeded))
+		if run.SupersededBy != "" {
+			result += " (superseded by " + run.SupersededBy + ")"
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", pipelineRun.Name, valueOrNone(runCommit(repo, run)), result,
+			runTimes(pipelineRun.Status.StartTime, pipelineRun.Status.CompletionTime))
+		for _, taskRun := range sortedTaskRuns(pipelineRun) {
+			if taskRun.Status == nil {
+				fmt.Fprintf(w, "    %s\t\tPending\t\n", taskRun.PipelineTaskName)
+				continue
+			}
+			fmt.Fprintf(w, "    %s\t\t%s\t%s\n", taskRun.PipelineTaskName, runResult(taskRun.Status.GetCondition(knative.ConditionSucceeded)),
+				runTimes(taskRun.Status.StartTime, taskRun.Status.CompletionTime))
+		}
+	}
+
+	fmt.Fprintln(w, "Events:")
+	if len(d.events) == 0 {
+		fmt.Fprintln(w, "  <none>")
+	} else {
+		fmt.Fprintln(w, "  TYPE\tREASON\tAGE\tFROM\tMESSAGE")
+		for _, event := range d.events {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\t%s\n", event.Type, event.Reason, age(metav1.NewTime(eventTime(event))),
+				event.Source.Component, strings.TrimSpace(event.Message))
+		}
+	}
+
+	return w.Flush()
+}
+
+// runResult returns the reason of a Succeeded condition, or its status if
+// it has none
+func runResult(condition *knative.Condition) string {
+	switch {
+	case condition == nil:
+		return "Pending"
+	case condition.Reason != "":
+		return condition.Reason
+	case condition.IsTrue():
+		return alphav1.ReasonSucceeded
+	case condition.IsFalse():
+		return alphav1.ReasonFailed
+	default:
+		return alphav1.ReasonRunning
+	}
+}
+
+// runTimes describes when a run started and how long it took, or has taken so far
+func runTimes(start, completion *metav1.Time) string {
+	if start == nil {
+		return "not started"
+	}
+
+	if completion == nil {
+		return fmt.Sprintf("started %s ago, running for %s", age(*start), duration.HumanDuration(time.Since(start.Time)))
+	}
+
+	return fmt.Sprintf("started %s ago, took %s", age(*start), duration.HumanDuration(completion.Sub(start.Time)))
+}
+
+// eventTime returns when an Event last happened
+func eventTime(event corev1.Event) time.Time {
+	if !event.LastTimestamp.IsZero() {
+		return event.LastTimestamp.Time
+	}
+
+	return event.CreationTimestamp.Time
+}
+
+func init() {
+	// optional
+	describeRepoCmd.Flags().StringVarP(&dso.Namespace, "namespace", "", "default", "namespace repo is in")
+	describeRepoCmd.Flags().StringVarP(&dso.Output, "output", "o", "", "print the Repo, its Pipeline, PipelineRuns and Events as yaml or json instead")
+
+	describeCmd.AddCommand(describeRepoCmd)
+	rootCmd.AddCommand(describeCmd)
+}
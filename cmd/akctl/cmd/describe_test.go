@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	knative "knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+)
+
+var _ = Describe("Describe tests", func() {
+	var (
+		ctx     = context.Background()
+		key     = types.NamespacedName{Namespace: "default", Name: "repo"}
+		now     = time.Now()
+		repo    *alphav1.Repo
+		objects []runtime.Object
+	)
+
+	ref := func(name string) *corev1.ObjectReference {
+		return &corev1.ObjectReference{Kind: "PipelineRun", Namespace: key.Namespace, Name: name}
+	}
+
+	event := func(name string, uid types.UID, ago time.Duration) *corev1.Event {
+		return &corev1.Event{
+			ObjectMeta:     metav1.ObjectMeta{Name: name, Namespace: key.Namespace},
+			InvolvedObject: corev1.ObjectReference{UID: uid},
+			Type:           corev1.EventTypeNormal,
+			Reason:         "Synced",
+			Message:        name,
+			LastTimestamp:  metav1.NewTime(now.Add(-ago)),
+			Source:         corev1.EventSource{Component: "alaska"},
+		}
+	}
+
+	describe := func() *repoDescription {
+		c := fake.NewFakeClientWithScheme(scheme, append(objects, repo)...)
+		d, err := getRepoDescription(ctx, c, key)
+		Expect(err).NotTo(HaveOccurred())
+		return d
+	}
+
+	BeforeEach(func() {
+		started := metav1.NewTime(now.Add(-time.Hour))
+		finished := metav1.NewTime(now.Add(-50 * time.Minute))
+
+		succeeded := &tektonv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo-6104942-x7k2p", Namespace: key.Namespace, UID: "run-uid"},
+		}
+		succeeded.Status.SetCondition(&knative.Condition{Type: knative.ConditionSucceeded, Status: corev1.ConditionTrue, Reason: "Succeeded"})
+		succeeded.Status.StartTime = &started
+		succeeded.Status.CompletionTime = &finished
+
+		apply := &tektonv1.TaskRunStatus{StartTime: &started, CompletionTime: &finished}
+		apply.SetCondition(&knative.Condition{Type: knative.ConditionSucceeded, Status: corev1.ConditionTrue})
+		succeeded.Status.TaskRuns = map[string]*tektonv1.PipelineRunTaskRunStatus{
+			"repo-6104942-x7k2p-apply-abcde": {PipelineTaskName: "apply", Status: apply},
+			"repo-6104942-x7k2p-smoke-fghij": {PipelineTaskName: "smoke"},
+		}
+
+		running := &tektonv1.PipelineRun{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo-3f2a9c1-q8w3e", Namespace: key.Namespace, UID: "running-uid"},
+		}
+
+		pipeline := &tektonv1.Pipeline{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace},
+			Spec: tektonv1.PipelineSpec{Tasks: []tektonv1.PipelineTask{{
+				Name:    "apply",
+				TaskRef: tektonv1.TaskRef{Name: "kubectl-apply"},
+				Params:  []tektonv1.Param{{Name: "path", Value: tektonv1.ArrayOrString{Type: tektonv1.ParamTypeString, StringVal: "deploy.yaml"}}},
+			}}},
+		}
+
+		objects = []runtime.Object{succeeded, running, pipeline}
+
+		repo = &alphav1.Repo{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace, UID: "repo-uid"},
+			Spec:       alphav1.RepoSpec{URL: "https://github.com/rudoi/alaska", Branch: "master", Cluster: "production"},
+			Status: alphav1.RepoStatus{
+				CommitSHA: "3f2a9c1",
+				Runs: []*alphav1.PipelineStatus{
+					{Ref: ref("repo-3f2a9c1-q8w3e"), Commit: "3f2a9c1"},
+					nil,
+					{Commit: "9c8b7a6", Status: "Failed", Completed: true},
+					{Ref: ref("repo-1234567-gone1"), Status: "Succeeded", Completed: true, Succeeded: true},
+					{Ref: ref("repo-6104942-x7k2p"), Completed: true, Succeeded: true},
+				},
+			},
+		}
+	})
+
+	Context("getRepoDescription", func() {
+		It("should pair each run with its PipelineRun", func() {
+			d := describe()
+			Expect(d.pipeline).NotTo(BeNil())
+			Expect(d.runs).To(HaveLen(4))
+
+			Expect(d.runs[0].status.Commit).To(Equal("3f2a9c1"))
+			Expect(d.runs[0].pipelineRun.Name).To(Equal("repo-3f2a9c1-q8w3e"))
+
+			Expect(d.runs[1].status.Commit).To(Equal("9c8b7a6"))
+			Expect(d.runs[1].pipelineRun).To(BeNil())
+
+			Expect(d.runs[2].status.Ref.Name).To(Equal("repo-1234567-gone1"))
+			Expect(d.runs[2].pipelineRun).To(BeNil())
+
+			Expect(d.runs[3].status.Ref.Name).To(Equal("repo-6104942-x7k2p"))
+			Expect(d.runs[3].pipelineRun.Name).To(Equal("repo-6104942-x7k2p"))
+		})
+
+		It("should describe a Repo without a Pipeline", func() {
+			objects = objects[:2]
+			Expect(describe().pipeline).To(BeNil())
+		})
+
+		It("should fail for a Repo that doesn't exist", func() {
+			c := fake.NewFakeClientWithScheme(scheme, objects...)
+			_, err := getRepoDescription(ctx, c, key)
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		})
+
+		It("should keep the latest Events about the Repo and its PipelineRuns, oldest first", func() {
+			objects = append(objects,
+				event("about-repo", "repo-uid", 3*time.Minute),
+				event("about-run", "run-uid", 5*time.Minute),
+				event("about-running", "running-uid", time.Minute),
+				event("about-something-else", "other-uid", 2*time.Minute),
+			)
+
+			messages := []string{}
+			for _, event := range describe().events {
+				messages = append(messages, event.Message)
+			}
+			Expect(messages).To(Equal([]string{"about-run", "about-repo", "about-running"}))
+		})
+
+		It("should keep at most the describe event limit", func() {
+			for i := 0; i < describeEventLimit+2; i++ {
+				objects = append(objects, event(fmt.Sprintf("event-%02d", i), "repo-uid", time.Duration(i)*time.Minute))
+			}
+
+			events := describe().events
+			Expect(events).To(HaveLen(describeEventLimit))
+			Expect(events[0].Message).To(Equal(fmt.Sprintf("event-%02d", describeEventLimit-1)))
+			Expect(events[len(events)-1].Message).To(Equal("event-00"))
+		})
+	})
+
+	Context("printing", func() {
+		It("should list each run with what's known of it", func() {
+			out := &bytes.Buffer{}
+			Expect(describe().print(out)).To(Succeed())
+
+			Expect(out.String()).To(MatchRegexp(`(?m)^  repo-3f2a9c1-q8w3e +3f2a9c1 +Pending +not started$`))
+			Expect(out.String()).To(MatchRegexp(`(?m)^  <unknown> +9c8b7a6 +Failed +\(no PipelineRun recorded\)$`))
+			Expect(out.String()).To(MatchRegexp(`(?m)^  repo-1234567-gone1 +1234567 +Succeeded +\(deleted\)$`))
+			Expect(out.String()).To(MatchRegexp(`(?m)^  repo-6104942-x7k2p +6104942 +Succeeded +started 60m ago, took 10m$`))
+			Expect(out.String()).To(MatchRegexp(`(?m)^    apply +Succeeded +started 60m ago, took 10m$`))
+			Expect(out.String()).To(MatchRegexp(`(?m)^    smoke +Pending\s*$`))
+			Expect(out.String()).To(MatchRegexp(`(?m)^  apply +kubectl-apply +deploy.yaml +<none>$`))
+		})
+
+		It("should print the Repo, its Pipeline, the PipelineRuns that exist and the Events as objects", func() {
+			objects = append(objects, event("about-repo", "repo-uid", time.Minute))
+
+			kinds := []string{}
+			for _, object := range describe().objects() {
+				kinds = append(kinds, object.GetObjectKind().GroupVersionKind().Kind)
+			}
+			Expect(kinds).To(Equal([]string{"Repo", "Pipeline", "PipelineRun", "PipelineRun", "Event"}))
+		})
+	})
+})
@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/duration"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+type GetOptions struct {
+	Namespace     string
+	AllNamespaces bool
+	Output        string
+}
+
+var getCmd = &cobra.Command{
+	Use:   "get",
+	Short: "display Alaska resources",
+}
+
+var gto = &GetOptions{}
+var getReposCmd = &cobra.Command{
+	Use:     "repos [name]",
+	Aliases: []string{"repo", "rp"},
+	Short:   "list Repos",
+	Long:    "list Repos with the commit they're at and the result of their latest run",
+	Args:    cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := ""
+		if len(args) > 0 {
+			name = args[0]
+		}
+
+		if err := RunGetRepos(name, gto); err != nil {
+			klog.Exit(err)
+		}
+	},
+}
+
+func RunGetRepos(name string, gto *GetOptions) error {
+	switch gto.Output {
+	case "", "wide", "yaml", "json":
+	default:
+		return fmt.Errorf("unknown output format %q, expected wide, yaml or json", gto.Output)
+	}
+
+	ctx := context.Background()
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+
+	repos := []alphav1.Repo{}
+	if name != "" {
+		repo := &alphav1.Repo{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: gto.Namespace, Name: name}, repo); err != nil {
+			return err
+		}
+		repos = append(repos, *repo)
+	} else {
+		list := &alphav1.RepoList{}
+		opts := []client.ListOption{}
+		if !gto.AllNamespaces {
+			opts = append(opts, client.InNamespace(gto.Namespace))
+		}
+		if err := c.List(ctx, list, opts...); err != nil {
+			return err
+		}
+		repos = list.Items
+	}
+
+	if gto.Output == "yaml" || gto.Output == "json" {
+		objects := []runtime.Object{}
+		for i := range repos {
+			repos[i].TypeMeta = metav1.TypeMeta{APIVersion: alphav1.GroupVersion.String(), Kind: "Repo"}
+			objects = append(objects, &repos[i])
+		}
+		return printObjects(objects, gto.Output)
+	}
+
+	if len(repos) == 0 {
+		fmt.Fprintln(os.Stderr, "No repos found.")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 3, ' ', 0)
+	header := "NAME\tURL\tBRANCH\tCLUSTER\tCOMMIT\tLAST RUN\tAGE"
+	if gto.AllNamespaces {
+		header = "NAMESPACE\t" + header
+	}
+	if gto.Output == "wide" {
+		header += "\tREADY\tREASON\tSUSPENDED\tPROVIDER\tCONFIG"
+	}
+	fmt.Fprintln(w, header)
+
+	for _, repo := range repos {
+		row := fmt.Sprintf("%s\t%s\t%s\t%s\t%s\t%s\t%s", repo.Name, repo.Spec.URL, repo.Spec.Branch, repo.Spec.Cluster,
+			valueOrNone(repo.Status.CommitSHA), lastRun(&repo), age(repo.CreationTimestamp))
+		if gto.AllNamespaces {
+			row = repo.Namespace + "\t" + row
+		}
+		if gto.Output == "wide" {
+			ready, reason := "Unknown", "<none>"
+			if condition := repo.Status.GetCondition(alphav1.ConditionReady); condition != nil {
+				ready, reason = string(condition.Status), valueOrNone(condition.Reason)
+			}
+			row += fmt.Sprintf("\t%s\t%s\t%t\t%s\t%s", ready, reason, repo.Status.IsSuspended(), valueOrNone(string(repo.Spec.Provider)), repo.GetConfigPath())
+		}
+		fmt.Fprintln(w, row)
+	}
+
+	return w.Flush()
+}
+
+// lastRun returns the result of the Repo's latest run
+func lastRun(repo *alphav1.Repo) string {
+	if len(repo.Status.Runs) == 0 || repo.Status.Runs[0].Ref == nil {
+		return "<none>"
+	}
+
+	run := repo.Status.Runs[0]
+	switch {
+	case run.Status != "":
+		return run.Status
+	case !run.Completed:
+		return alphav1.ReasonRunning
+	case run.Succeeded:
+		return alphav1.ReasonSucceeded
+	default:
+		return alphav1.ReasonFailed
+	}
+}
+
+func age(t metav1.Time) string {
+	if t.IsZero() {
+		return "<unknown>"
+	}
+
+	return duration.HumanDuration(time.Since(t.Time))
+}
+
+func valueOrNone(s string) string {
+	if s == "" {
+		return "<none>"
+	}
+
+	return s
+}
+
+func init() {
+	// optional
+	getReposCmd.Flags().StringVarP(&gto.Namespace, "namespace", "", "default", "namespace repos are in")
+	getReposCmd.Flags().BoolVarP(&gto.AllNamespaces, "all-namespaces", "A", false, "list repos in every namespace")
+	getReposCmd.Flags().StringVarP(&gto.Output, "output", "o", "", "output format, wide, yaml or json")
+
+	getCmd.AddCommand(getReposCmd)
+	rootCmd.AddCommand(getCmd)
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+	"github.com/rudoi/alaska/pkg/alaska"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v2"
+)
+
+// LocalOptions point at an alaska.yaml in a local checkout
+type LocalOptions struct {
+	ConfigPath string
+	ToolImages string
+}
+
+// addLocalFlags registers the flags of LocalOptions on cmd
+func addLocalFlags(flags *pflag.FlagSet, lo *LocalOptions) {
+	flags.StringVarP(&lo.ConfigPath, "config-path", "", alphav1.DefaultConfigPath, "path of the config file within the repo")
+	flags.StringVarP(&lo.ToolImages, "tool-images", "", "", "the controller's --tool-images file, to check pinned tool versions against - any version is accepted without it")
+}
+
+// loadLocalConfig loads the config of the checkout in dir the way the
+// controller loads it at a commit
+func loadLocalConfig(dir string, lo *LocalOptions) (*alphav1.Config, error) {
+	read := func(path string) ([]byte, error) {
+		return ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+	}
+
+	return alaska.LoadConfig(read, lo.ConfigPath)
+}
+
+// localToolImages returns the tool images to build config's Pipeline with
+func localToolImages(config *alphav1.Config, lo *LocalOptions) (alphav1.ToolImages, error) {
+	images := alphav1.ToolImages{}
+	if lo.ToolImages == "" {
+		// accept every version the config pins, naming the image after it
+		pin := func(tools alphav1.Tools) {
+			for tool, version := range tools {
+				if images[tool] == nil {
+					images[tool] = map[string]string{}
+				}
+				images[tool][version] = fmt.Sprintf("%s:%s", tool, version)
+			}
+		}
+
+		pin(config.Tools)
+		for _, manifest := range config.Manifests {
+			pin(manifest.Tools)
+		}
+		for _, stage := range config.Stages {
+			for _, manifest := range stage.Manifests {
+				pin(manifest.Tools)
+			}
+		}
+
+		return images, nil
+	}
+
+	data, err := ioutil.ReadFile(lo.ToolImages)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, &images); err != nil {
+		return nil, fmt.Errorf("unable to parse tool images: %v", err)
+	}
+
+	return images, nil
+}
+
+// localHead returns the abbreviated commit checked out in dir, like the
+// controller's Status.CommitSHA
+func localHead(dir string) (string, error) {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return "", fmt.Errorf("unable to find the commit checked out in %s, use --sha: %v", dir, err)
+	}
+
+	head := strings.TrimSpace(string(out))
+	if len(head) < 7 {
+		return "", fmt.Errorf("the commit checked out in %s resolved to %q, not a commit SHA, use --sha", dir, head)
+	}
+
+	return head[:7], nil
+}
+
+// exitConfigErrors prints each of a config's errors on its own line and
+// exits, or returns if err isn't about the config
+func exitConfigErrors(err error) {
+	var errs []*alaska.ConfigError
+	switch e := err.(type) {
+	case *alaska.ParseError:
+		errs = e.Errors
+	case *alaska.ValidationError:
+		errs = e.Errors
+	default:
+		return
+	}
+
+	for _, configErr := range errs {
+		fmt.Fprintln(os.Stderr, configErr)
+	}
+	os.Exit(1)
+}
@@ -0,0 +1,251 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+	"github.com/spf13/cobra"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/klog"
+	knative "knative.dev/pkg/apis"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// stepContainerPrefix prefixes the name of the container Tekton runs each step in
+const stepContainerPrefix = "step-"
+
+// logsPollInterval is how often a followed PipelineRun is checked for new TaskRuns
+const logsPollInterval = 2 * time.Second
+
+type LogsOptions struct {
+	Namespace string
+
+	// Run is the index of the run in the Repo's Status.Runs, 0 being the latest
+	Run    int
+	Follow bool
+	Task   string
+}
+
+var lgo = &LogsOptions{}
+var logsCmd = &cobra.Command{
+	Use:   "logs <repo>",
+	Short: "print the logs of a Repo's pipeline",
+	Long:  "print the step logs of each TaskRun of a Repo's latest PipelineRun, or an earlier one with --run",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := RunLogs(args[0], lgo); err != nil {
+			klog.Exit(err)
+		}
+	},
+}
+
+func RunLogs(name string, lo *LogsOptions) error {
+	ctx := context.Background()
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return err
+	}
+
+	repo := &alphav1.Repo{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: lo.Namespace, Name: name}, repo); err != nil {
+		return err
+	}
+
+	if lo.Run < 0 || lo.Run >= len(repo.Status.Runs) || repo.Status.Runs[lo.Run].Ref == nil {
+		return fmt.Errorf("repo %s has %d runs, no run %d", name, len(repo.Status.Runs), lo.Run)
+	}
+	ref := repo.Status.Runs[lo.Run].Ref
+
+	streamer := &logStreamer{
+		pods:   clientset.CoreV1().Pods(ref.Namespace),
+		out:    os.Stdout,
+		follow: lo.Follow,
+	}
+
+	streamed := map[string]bool{}
+	for {
+		pipelineRun := &tektonv1.PipelineRun{}
+		if err := c.Get(ctx, types.NamespacedName{Namespace: ref.Namespace, Name: ref.Name}, pipelineRun); err != nil {
+			return err
+		}
+
+		for _, taskRun := range sortedTaskRuns(pipelineRun) {
+			if lo.Task != "" && taskRun.PipelineTaskName != lo.Task {
+				continue
+			}
+			if streamed[taskRun.name] || taskRun.Status == nil || taskRun.Status.PodName == "" {
+				continue
+			}
+			streamed[taskRun.name] = true
+
+			if lo.Follow {
+				// TaskRuns running side by side are followed side by side
+				streamer.wg.Add(1)
+				go func(task, pod string) {
+					defer streamer.wg.Done()
+					streamer.streamTask(task, pod)
+				}(taskRun.PipelineTaskName, taskRun.Status.PodName)
+				continue
+			}
+
+			streamer.streamTask(taskRun.PipelineTaskName, taskRun.Status.PodName)
+		}
+
+		if !lo.Follow || pipelineRunDone(pipelineRun) {
+			break
+		}
+		time.Sleep(logsPollInterval)
+	}
+	streamer.wg.Wait()
+
+	if len(streamed) == 0 && lo.Task != "" {
+		return fmt.Errorf("PipelineRun %s has no TaskRun for task %q", ref.Name, lo.Task)
+	}
+
+	return nil
+}
+
+type namedTaskRun struct {
+	name string
+	*tektonv1.PipelineRunTaskRunStatus
+}
+
+// sortedTaskRuns returns the PipelineRun's TaskRuns in the order they started
+func sortedTaskRuns(pipelineRun *tektonv1.PipelineRun) []namedTaskRun {
+	taskRuns := []namedTaskRun{}
+	for name, status := range pipelineRun.Status.TaskRuns {
+		taskRuns = append(taskRuns, namedTaskRun{name: name, PipelineRunTaskRunStatus: status})
+	}
+
+	startTime := func(tr namedTaskRun) time.Time {
+		if tr.Status == nil || tr.Status.StartTime == nil {
+			return time.Time{}
+		}
+		return tr.Status.StartTime.Time
+	}
+	sort.Slice(taskRuns, func(i, j int) bool {
+		if !startTime(taskRuns[i]).Equal(startTime(taskRuns[j])) {
+			return startTime(taskRuns[i]).Before(startTime(taskRuns[j]))
+		}
+		return taskRuns[i].name < taskRuns[j].name
+	})
+
+	return taskRuns
+}
+
+func pipelineRunDone(pipelineRun *tektonv1.PipelineRun) bool {
+	condition := pipelineRun.Status.GetCondition(knative.ConditionSucceeded)
+	return condition != nil && !condition.IsUnknown()
+}
+
+// logStreamer copies step container logs to out, prefixing each line with
+// its task and step
+type logStreamer struct {
+	pods   typedcorev1.PodInterface
+	out    io.Writer
+	follow bool
+
+	mu sync.Mutex
+	wg sync.WaitGroup
+}
+
+// streamTask streams the logs of each step of a TaskRun's pod in turn
+func (l *logStreamer) streamTask(task, podName string) {
+	pod, err := l.pods.Get(podName, metav1.GetOptions{})
+	if err != nil {
+		l.printf("[%s] unable to get pod %s: %v\n", task, podName, err)
+		return
+	}
+
+	for _, container := range pod.Spec.Containers {
+		if !strings.HasPrefix(container.Name, stepContainerPrefix) {
+			continue
+		}
+		prefix := fmt.Sprintf("[%s %s] ", task, strings.TrimPrefix(container.Name, stepContainerPrefix))
+
+		if !l.waitForStart(podName, container.Name) {
+			continue
+		}
+
+		stream, err := l.pods.GetLogs(podName, &corev1.PodLogOptions{Container: container.Name, Follow: l.follow}).Stream()
+		if err != nil {
+			l.printf("%sunable to get logs: %v\n", prefix, err)
+			continue
+		}
+
+		scanner := bufio.NewScanner(stream)
+		for scanner.Scan() {
+			l.printf("%s%s\n", prefix, scanner.Text())
+		}
+		stream.Close()
+	}
+}
+
+// waitForStart returns true once the container has started. Unless following,
+// or if the pod finished without running it, it returns false right away.
+func (l *logStreamer) waitForStart(podName, container string) bool {
+	for {
+		pod, err := l.pods.Get(podName, metav1.GetOptions{})
+		switch {
+		case apierrors.IsNotFound(err):
+			return false
+		case err != nil:
+			if !l.follow {
+				return false
+			}
+		default:
+			for _, status := range pod.Status.ContainerStatuses {
+				if status.Name == container && (status.State.Running != nil || status.State.Terminated != nil) {
+					return true
+				}
+			}
+
+			if !l.follow || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+				return false
+			}
+		}
+
+		time.Sleep(logsPollInterval)
+	}
+}
+
+func (l *logStreamer) printf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	fmt.Fprintf(l.out, format, args...)
+}
+
+func init() {
+	// optional
+	logsCmd.Flags().StringVarP(&lgo.Namespace, "namespace", "", "default", "namespace repo is in")
+	logsCmd.Flags().IntVarP(&lgo.Run, "run", "", 0, "run to print, counting back from the latest (0)")
+	logsCmd.Flags().BoolVarP(&lgo.Follow, "follow", "f", false, "stream logs until the run finishes, following tasks as they start")
+	logsCmd.Flags().StringVarP(&lgo.Task, "task", "", "", "only print the logs of this pipeline task")
+
+	rootCmd.AddCommand(logsCmd)
+}
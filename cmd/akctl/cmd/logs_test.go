@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	knative "knative.dev/pkg/apis"
+)
+
+var _ = Describe("Logs tests", func() {
+	Describe("sortedTaskRuns", func() {
+		It("should order TaskRuns by start time, then name, those not started first", func() {
+			at := func(minute int) *tektonv1.PipelineRunTaskRunStatus {
+				start := metav1.NewTime(time.Date(2019, 10, 1, 12, minute, 0, 0, time.UTC))
+				return &tektonv1.PipelineRunTaskRunStatus{Status: &tektonv1.TaskRunStatus{StartTime: &start}}
+			}
+
+			pipelineRun := &tektonv1.PipelineRun{}
+			pipelineRun.Status.TaskRuns = map[string]*tektonv1.PipelineRunTaskRunStatus{
+				"run-smoke":   at(5),
+				"run-migrate": at(1),
+				"run-apply-b": at(3),
+				"run-apply-a": at(3),
+				"run-pending": {},
+			}
+
+			names := []string{}
+			for _, taskRun := range sortedTaskRuns(pipelineRun) {
+				names = append(names, taskRun.name)
+			}
+			Expect(names).To(Equal([]string{"run-pending", "run-migrate", "run-apply-a", "run-apply-b", "run-smoke"}))
+		})
+	})
+
+	Describe("pipelineRunDone", func() {
+		table.DescribeTable("should be done once the Succeeded condition is known",
+			func(condition *knative.Condition, done bool) {
+				pipelineRun := &tektonv1.PipelineRun{}
+				pipelineRun.Status.SetCondition(condition)
+				Expect(pipelineRunDone(pipelineRun)).To(Equal(done))
+			},
+			table.Entry("without a condition", nil, false),
+			table.Entry("running", &knative.Condition{Type: knative.ConditionSucceeded, Status: corev1.ConditionUnknown}, false),
+			table.Entry("succeeded", &knative.Condition{Type: knative.ConditionSucceeded, Status: corev1.ConditionTrue}, true),
+			table.Entry("failed", &knative.Condition{Type: knative.ConditionSucceeded, Status: corev1.ConditionFalse}, true),
+		)
+	})
+
+	Describe("logStreamer", func() {
+		var (
+			server   *httptest.Server
+			pod      *corev1.Pod
+			out      *bytes.Buffer
+			streamer *logStreamer
+		)
+
+		started := corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{}}
+
+		BeforeEach(func() {
+			pod = &corev1.Pod{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Pod"},
+				ObjectMeta: metav1.ObjectMeta{Name: "run-apply-pod", Namespace: "default"},
+				Spec: corev1.PodSpec{Containers: []corev1.Container{
+					{Name: "step-git-source"},
+					{Name: "step-apply"},
+					{Name: "step-verify"},
+					{Name: "sidecar"},
+				}},
+				Status: corev1.PodStatus{
+					Phase: corev1.PodFailed,
+					ContainerStatuses: []corev1.ContainerStatus{
+						{Name: "step-git-source", State: started},
+						{Name: "step-apply", State: started},
+						{Name: "step-verify", State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{}}},
+						{Name: "sidecar", State: started},
+					},
+				},
+			}
+
+			logs := map[string]string{
+				"step-git-source": "cloned\n",
+				"step-apply":      "deployment.apps/app configured\nservice/app unchanged\n",
+				"sidecar":         "not a step\n",
+			}
+
+			mux := http.NewServeMux()
+			mux.HandleFunc("/api/v1/namespaces/default/pods/run-apply-pod", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				Expect(json.NewEncoder(w).Encode(pod)).To(Succeed())
+			})
+			mux.HandleFunc("/api/v1/namespaces/default/pods/run-apply-pod/log", func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte(logs[r.URL.Query().Get("container")]))
+			})
+			server = httptest.NewServer(mux)
+
+			clientset, err := kubernetes.NewForConfig(&rest.Config{Host: server.URL})
+			Expect(err).NotTo(HaveOccurred())
+
+			out = &bytes.Buffer{}
+			streamer = &logStreamer{pods: clientset.CoreV1().Pods("default"), out: out}
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("should prefix each line with its task and step", func() {
+			streamer.streamTask("apply", "run-apply-pod")
+			Expect(out.String()).To(Equal(
+				"[apply git-source] cloned\n" +
+					"[apply apply] deployment.apps/app configured\n" +
+					"[apply apply] service/app unchanged\n"))
+		})
+
+		It("should report a pod it can't get", func() {
+			streamer.streamTask("apply", "gone-pod")
+			Expect(out.String()).To(HavePrefix("[apply] unable to get pod gone-pod: "))
+		})
+	})
+})
@@ -0,0 +1,193 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+	"github.com/rudoi/alaska/pkg/alaska"
+	"github.com/spf13/cobra"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog"
+	"sigs.k8s.io/yaml"
+)
+
+type RenderOptions struct {
+	LocalOptions
+
+	Name      string
+	Namespace string
+	Cluster   string
+	SHA       string
+	Output    string
+}
+
+var rdo = &RenderOptions{}
+var renderCmd = &cobra.Command{
+	Use:   "render [dir]",
+	Short: "render the Tekton resources for an alaska.yaml",
+	Long:  "print the Pipeline, generic executor Tasks and a sample PipelineRun the controller would create for the alaska.yaml of a local checkout, without touching any cluster",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+
+		if err := RunRender(dir, rdo); err != nil {
+			exitConfigErrors(err)
+			klog.Exit(err)
+		}
+	},
+}
+
+func RunRender(dir string, ro *RenderOptions) error {
+	if ro.Output != "yaml" && ro.Output != "json" {
+		return fmt.Errorf("unknown output format %q, expected yaml or json", ro.Output)
+	}
+
+	objects, err := renderObjects(dir, ro)
+	if err != nil {
+		return err
+	}
+
+	return printObjects(objects, ro.Output)
+}
+
+// renderObjects returns the generic executor Tasks, the Pipeline and a
+// sample PipelineRun for the checkout in dir, as the controller would create
+// them
+func renderObjects(dir string, ro *RenderOptions) ([]runtime.Object, error) {
+	config, err := loadLocalConfig(dir, &ro.LocalOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	images, err := localToolImages(config, &ro.LocalOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	spec, err := config.ToPipelineSpec(images)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", ro.ConfigPath, err)
+	}
+
+	repo := &alphav1.Repo{
+		ObjectMeta: metav1.ObjectMeta{Name: ro.Name, Namespace: ro.Namespace},
+		Spec:       alphav1.RepoSpec{Cluster: ro.Cluster},
+	}
+	if repo.Name == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		repo.Name = filepath.Base(abs)
+	}
+
+	sha := ro.SHA
+	if sha == "" {
+		if sha, err = localHead(dir); err != nil {
+			return nil, err
+		}
+	}
+
+	objects := []runtime.Object{}
+
+	generic := config.GenericTasks()
+	names := []string{}
+	for name := range generic {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		objects = append(objects, &tektonv1.Task{
+			TypeMeta:   metav1.TypeMeta{APIVersion: tektonv1.SchemeGroupVersion.String(), Kind: "Task"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: repo.Namespace},
+			Spec:       generic[name],
+		})
+	}
+
+	objects = append(objects,
+		&tektonv1.Pipeline{
+			TypeMeta:   metav1.TypeMeta{APIVersion: tektonv1.SchemeGroupVersion.String(), Kind: "Pipeline"},
+			ObjectMeta: metav1.ObjectMeta{Name: repo.Name, Namespace: repo.Namespace},
+			Spec:       spec,
+		},
+		alaska.NewPipelineRun(repo, sha),
+	)
+
+	for i := range objects {
+		if objects[i], err = offline(objects[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	return objects, nil
+}
+
+// offline strips what only exists on a cluster: the status, the creation
+// timestamp and the owner reference to a Repo that was never created
+func offline(object runtime.Object) (runtime.Object, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	if err != nil {
+		return nil, err
+	}
+
+	unstructured.RemoveNestedField(content, "status")
+	unstructured.RemoveNestedField(content, "metadata", "creationTimestamp")
+	unstructured.RemoveNestedField(content, "metadata", "ownerReferences")
+
+	return &unstructured.Unstructured{Object: content}, nil
+}
+
+// printObjects prints objects as YAML documents, or as a JSON List
+func printObjects(objects []runtime.Object, output string) error {
+	if output == "json" {
+		list := map[string]interface{}{
+			"apiVersion": "v1",
+			"kind":       "List",
+			"items":      objects,
+		}
+
+		out, err := json.MarshalIndent(list, "", "    ")
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintln(os.Stdout, string(out))
+		return err
+	}
+
+	for i, object := range objects {
+		out, err := yaml.Marshal(object)
+		if err != nil {
+			return err
+		}
+
+		if i > 0 {
+			fmt.Println("---")
+		}
+		fmt.Print(string(out))
+	}
+
+	return nil
+}
+
+func init() {
+	// optional
+	addLocalFlags(renderCmd.Flags(), &rdo.LocalOptions)
+	renderCmd.Flags().StringVarP(&rdo.Name, "name", "", "", "name of the Repo - defaults to the directory's name")
+	renderCmd.Flags().StringVarP(&rdo.Namespace, "namespace", "", "default", "namespace of the Repo")
+	renderCmd.Flags().StringVarP(&rdo.Cluster, "cluster", "", "cluster", "cluster PipelineResource the Repo deploys to")
+	renderCmd.Flags().StringVarP(&rdo.SHA, "sha", "", "", "abbreviated commit of the sample PipelineRun - defaults to the one checked out")
+	renderCmd.Flags().StringVarP(&rdo.Output, "output", "o", "yaml", "output format, yaml or json")
+
+	rootCmd.AddCommand(renderCmd)
+}
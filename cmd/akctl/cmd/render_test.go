@@ -0,0 +1,136 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+)
+
+var _ = Describe("Render tests", func() {
+	var (
+		dir string
+		ro  *RenderOptions
+	)
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=alaska", "GIT_AUTHOR_EMAIL=alaska@example.com",
+			"GIT_COMMITTER_NAME=alaska", "GIT_COMMITTER_EMAIL=alaska@example.com",
+		)
+		out, err := cmd.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(out))
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "akctl-render-test-")
+		Expect(err).NotTo(HaveOccurred())
+
+		config := "manifests:\n- path: deploy.yaml\n- path: migrations\n  type: generic\n  image: migrate/migrate:v4.6.2\n"
+		Expect(ioutil.WriteFile(filepath.Join(dir, "alaska.yaml"), []byte(config), 0644)).To(Succeed())
+
+		ro = &RenderOptions{
+			LocalOptions: LocalOptions{ConfigPath: alphav1.DefaultConfigPath},
+			Namespace:    "apps",
+			Cluster:      "production",
+			SHA:          "3f2a9c1",
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Context("given a valid config", func() {
+		It("should render the generic Tasks, the Pipeline and a sample PipelineRun", func() {
+			objects, err := renderObjects(dir, ro)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objects).To(HaveLen(3))
+
+			kinds := []string{}
+			for _, object := range objects {
+				kinds = append(kinds, object.GetObjectKind().GroupVersionKind().Kind)
+			}
+			Expect(kinds).To(Equal([]string{"Task", "Pipeline", "PipelineRun"}))
+
+			pipeline := objects[1].(*unstructured.Unstructured)
+			Expect(pipeline.GetName()).To(Equal(filepath.Base(dir)))
+			Expect(pipeline.GetNamespace()).To(Equal("apps"))
+
+			run := objects[2].(*unstructured.Unstructured)
+			Expect(run.GetGenerateName()).To(Equal(filepath.Base(dir) + "-3f2a9c1-"))
+		})
+
+		It("should leave out what only exists on a cluster", func() {
+			objects, err := renderObjects(dir, ro)
+			Expect(err).NotTo(HaveOccurred())
+
+			for _, object := range objects {
+				content := object.(*unstructured.Unstructured).Object
+				Expect(content).NotTo(HaveKey("status"))
+				Expect(content["metadata"]).NotTo(HaveKey("creationTimestamp"))
+				Expect(content["metadata"]).NotTo(HaveKey("ownerReferences"))
+			}
+		})
+	})
+
+	Context("given an invalid config", func() {
+		It("should return the config's errors", func() {
+			Expect(ioutil.WriteFile(filepath.Join(dir, "alaska.yaml"), []byte("strategy: sequental\n"), 0644)).To(Succeed())
+
+			_, err := renderObjects(dir, ro)
+			Expect(err).To(MatchError(ContainSubstring("sequental")))
+		})
+	})
+
+	Context("without --sha", func() {
+		BeforeEach(func() {
+			ro.SHA = ""
+		})
+
+		It("should use the commit checked out", func() {
+			if _, err := exec.LookPath("git"); err != nil {
+				Skip("git binary not available")
+			}
+
+			git("init", "--quiet")
+			git("add", "alaska.yaml")
+			git("commit", "--quiet", "-m", "add config")
+
+			head, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+			Expect(err).NotTo(HaveOccurred())
+
+			objects, err := renderObjects(dir, ro)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objects[2].(*unstructured.Unstructured).GetGenerateName()).To(HaveSuffix("-" + string(head[:7]) + "-"))
+		})
+
+		It("should return an error outside a checkout", func() {
+			_, err := renderObjects(dir, ro)
+			Expect(err).To(MatchError(ContainSubstring("use --sha")))
+		})
+
+		It("should return an error rather than panic on a short commit", func() {
+			// a git that answers rev-parse with something too short for a SHA
+			bin := filepath.Join(dir, "bin")
+			Expect(os.Mkdir(bin, 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(bin, "git"), []byte("#!/bin/sh\necho abc\n"), 0755)).To(Succeed())
+
+			path := os.Getenv("PATH")
+			defer os.Setenv("PATH", path)
+			os.Setenv("PATH", bin+string(os.PathListSeparator)+path)
+
+			_, err := renderObjects(dir, ro)
+			Expect(err).To(MatchError(ContainSubstring(`resolved to "abc", not a commit SHA`)))
+		})
+	})
+})
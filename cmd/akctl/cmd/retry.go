@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 
 	alphav1 "github.com/rudoi/alaska/api/v1"
 	"github.com/rudoi/alaska/pkg/alaska"
@@ -64,6 +65,10 @@ func RunRetry(name string, ro *RetryOptions) error {
 		return err
 	}
 
+	if repo.Status.IsSuspended() {
+		return fmt.Errorf("repo/%s is suspended, resume it with akctl resume first", name)
+	}
+
 	patch := client.MergeFrom(repo.DeepCopyObject())
 
 	if err := alaska.TriggerPipeline(ctx, c, repo, repo.Status.Config, repo.Status.CommitSHA); err != nil {
@@ -79,7 +84,7 @@ func RunRetry(name string, ro *RetryOptions) error {
 
 func init() {
 	// optional
-	createServiceAccountCmd.Flags().StringVarP(&ro.Namespace, "namespace", "", "default", "namespace repo is in")
+	retryCmd.Flags().StringVarP(&ro.Namespace, "namespace", "", "default", "namespace repo is in")
 
 	rootCmd.AddCommand(retryCmd)
 }
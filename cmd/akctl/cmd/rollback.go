@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/user"
+	"sort"
+	"strings"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+type RollbackOptions struct {
+	Namespace   string
+	To          string
+	Previous    bool
+	Clear       bool
+	Reason      string
+	RequestedBy string
+}
+
+var rbo = &RollbackOptions{}
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback <repo>",
+	Short: "pin a Repo to a previously deployed commit",
+	Long: "set spec.revision to a commit from a successful run in the Repo's history, so it's deployed instead of the branch head.\n" +
+		"--clear removes the pin and the Repo follows its branch again.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := RunRollback(args[0], rbo); err != nil {
+			klog.Exit(err)
+		}
+	},
+}
+
+func RunRollback(name string, rbo *RollbackOptions) error {
+	switch {
+	case rbo.Clear && (rbo.To != "" || rbo.Previous):
+		return errors.New("--clear can't be combined with --to or --previous")
+	case !rbo.Clear && (rbo.To == "") == !rbo.Previous:
+		return errors.New("one of --to, --previous or --clear is required")
+	case !rbo.Clear && rbo.Reason == "":
+		return errors.New("--reason is required to pin a revision")
+	}
+
+	ctx := context.Background()
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+
+	repo := &alphav1.Repo{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: rbo.Namespace, Name: name}, repo); err != nil {
+		return err
+	}
+
+	patch := client.MergeFrom(repo.DeepCopyObject())
+
+	if rbo.Clear {
+		repo.Spec.Revision = ""
+		delete(repo.Annotations, alphav1.AnnotationPinnedBy)
+		delete(repo.Annotations, alphav1.AnnotationPinReason)
+
+		if err := c.Patch(ctx, repo, patch); err != nil {
+			return err
+		}
+
+		fmt.Printf("repo/%s is following %s again\n", name, repo.Spec.Branch)
+		return nil
+	}
+
+	sha, err := rollbackCommit(repo, rbo)
+	if err != nil {
+		return err
+	}
+
+	requestedBy := rbo.RequestedBy
+	if requestedBy == "" {
+		u, err := user.Current()
+		if err != nil {
+			return fmt.Errorf("unable to look up current user, set --requested-by: %v", err)
+		}
+		requestedBy = u.Username
+	}
+
+	repo.Spec.Revision = sha
+	if repo.Annotations == nil {
+		repo.Annotations = map[string]string{}
+	}
+	repo.Annotations[alphav1.AnnotationPinnedBy] = requestedBy
+	repo.Annotations[alphav1.AnnotationPinReason] = rbo.Reason
+
+	if err := c.Patch(ctx, repo, patch); err != nil {
+		return err
+	}
+
+	fmt.Printf("repo/%s pinned to %s, run akctl rollback %s --clear to follow %s again\n", name, sha, name, repo.Spec.Branch)
+	return nil
+}
+
+// rollbackCommit picks the commit to pin from the Repo's successful runs
+func rollbackCommit(repo *alphav1.Repo, rbo *RollbackOptions) (string, error) {
+	if rbo.To != "" && !alphav1.IsCommitSHA(rbo.To) {
+		return "", fmt.Errorf("--to %q isn't a commit SHA of 7 to 40 hex characters", rbo.To)
+	}
+
+	succeeded := []string{}
+	for _, run := range repo.Status.Runs {
+		if commit := runCommit(repo, run); run.Completed && run.Succeeded && alphav1.IsCommitSHA(commit) {
+			succeeded = append(succeeded, commit)
+		}
+	}
+
+	if len(succeeded) == 0 {
+		return "", fmt.Errorf("repo/%s has no successful runs in its history", repo.GetName())
+	}
+
+	if rbo.Previous {
+		for _, commit := range succeeded {
+			if commit != repo.Status.CommitSHA {
+				return commit, nil
+			}
+		}
+
+		return "", fmt.Errorf("repo/%s has no successful run of a commit other than %s, successful runs: %s",
+			repo.GetName(), repo.Status.CommitSHA, strings.Join(succeeded, ", "))
+	}
+
+	// history records abbreviated SHAs, --to may be shorter or longer
+	matches := map[string]bool{}
+	match := ""
+	for _, commit := range succeeded {
+		switch {
+		case strings.HasPrefix(commit, rbo.To):
+			match = commit
+		case strings.HasPrefix(rbo.To, commit):
+			match = rbo.To
+		default:
+			continue
+		}
+		matches[match] = true
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("%s isn't a successful run of repo/%s, successful runs: %s", rbo.To, repo.GetName(), strings.Join(succeeded, ", "))
+	case 1:
+		return match, nil
+	default:
+		ambiguous := []string{}
+		for commit := range matches {
+			ambiguous = append(ambiguous, commit)
+		}
+		sort.Strings(ambiguous)
+		return "", fmt.Errorf("%s matches more than one successful run of repo/%s: %s", rbo.To, repo.GetName(), strings.Join(ambiguous, ", "))
+	}
+}
+
+// runCommit returns the commit a run deployed. Runs recorded before
+// PipelineStatus.Commit existed only have it in their PipelineRun's name.
+func runCommit(repo *alphav1.Repo, run *alphav1.PipelineStatus) string {
+	if run.Commit != "" {
+		return run.Commit
+	}
+
+	if run.Ref == nil || !strings.HasPrefix(run.Ref.Name, repo.GetName()+"-") {
+		return ""
+	}
+
+	return strings.Split(strings.TrimPrefix(run.Ref.Name, repo.GetName()+"-"), "-")[0]
+}
+
+func init() {
+	// required, one of
+	rollbackCmd.Flags().StringVarP(&rbo.To, "to", "", "", "commit SHA of a successful run to pin, at least 7 characters")
+	rollbackCmd.Flags().BoolVarP(&rbo.Previous, "previous", "", false, "pin the latest successful run of a commit other than the deployed one")
+	rollbackCmd.Flags().BoolVarP(&rbo.Clear, "clear", "", false, "remove the pin, deploying the branch head again")
+
+	// required with --to and --previous
+	rollbackCmd.Flags().StringVarP(&rbo.Reason, "reason", "", "", "why the Repo is pinned, recorded in the "+alphav1.AnnotationPinReason+" annotation")
+
+	// optional
+	rollbackCmd.Flags().StringVarP(&rbo.Namespace, "namespace", "", "default", "namespace repo is in")
+	rollbackCmd.Flags().StringVarP(&rbo.RequestedBy, "requested-by", "", "", "who requested the pin, recorded in the "+alphav1.AnnotationPinnedBy+" annotation (default is the current user)")
+
+	rootCmd.AddCommand(rollbackCmd)
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	. "github.com/onsi/ginkgo"
+	"github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+)
+
+var _ = Describe("Rollback tests", func() {
+	succeeded := func(commit string) *alphav1.PipelineStatus {
+		return &alphav1.PipelineStatus{Completed: true, Succeeded: true, Commit: commit}
+	}
+	failed := func(commit string) *alphav1.PipelineStatus {
+		return &alphav1.PipelineStatus{Completed: true, Commit: commit}
+	}
+	running := func(commit string) *alphav1.PipelineStatus {
+		return &alphav1.PipelineStatus{Commit: commit}
+	}
+	// legacy is a successful run recorded before PipelineStatus.Commit existed
+	legacy := func(pipelineRun string) *alphav1.PipelineStatus {
+		return &alphav1.PipelineStatus{Completed: true, Succeeded: true, Ref: &corev1.ObjectReference{Name: pipelineRun}}
+	}
+
+	newRepo := func(deployed string, runs ...*alphav1.PipelineStatus) *alphav1.Repo {
+		return &alphav1.Repo{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "default"},
+			Status:     alphav1.RepoStatus{CommitSHA: deployed, Runs: runs},
+		}
+	}
+
+	Describe("RunRollback", func() {
+		table.DescribeTable("should check its flags before reaching the cluster",
+			func(rbo *RollbackOptions, message string) {
+				err := RunRollback("repo", rbo)
+				Expect(err).To(MatchError(ContainSubstring(message)))
+			},
+			table.Entry("no flags", &RollbackOptions{}, "one of --to, --previous or --clear is required"),
+			table.Entry("--to and --previous", &RollbackOptions{To: "3f2a9c1", Previous: true, Reason: "bad"}, "one of --to, --previous or --clear is required"),
+			table.Entry("--clear and --to", &RollbackOptions{Clear: true, To: "3f2a9c1"}, "--clear can't be combined"),
+			table.Entry("--clear and --previous", &RollbackOptions{Clear: true, Previous: true}, "--clear can't be combined"),
+			table.Entry("--to without --reason", &RollbackOptions{To: "3f2a9c1"}, "--reason is required"),
+			table.Entry("--previous without --reason", &RollbackOptions{Previous: true}, "--reason is required"),
+		)
+	})
+
+	Describe("rollbackCommit with --to", func() {
+		var repo *alphav1.Repo
+
+		BeforeEach(func() {
+			repo = newRepo("3f2a9c1",
+				running("abcdef0"),
+				succeeded("3f2a9c1"),
+				failed("1234567"),
+				succeeded("9c8b7a6f5"),
+				succeeded("9c8b7a6e4"),
+				legacy("repo-6104942-x7k2p"),
+			)
+		})
+
+		table.DescribeTable("should match --to against the successful runs",
+			func(to, expected string) {
+				sha, err := rollbackCommit(repo, &RollbackOptions{To: to})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(sha).To(Equal(expected))
+			},
+			table.Entry("the recorded commit", "3f2a9c1", "3f2a9c1"),
+			table.Entry("a longer SHA, pinning it in full", "3f2a9c1d0e8b7a6f5e4d3c2b1a0f9e8d7c6b5a49", "3f2a9c1d0e8b7a6f5e4d3c2b1a0f9e8d7c6b5a49"),
+			table.Entry("a shorter SHA, pinning the recorded commit", "9c8b7a6f", "9c8b7a6f5"),
+			table.Entry("a commit only found in a PipelineRun's name", "6104942", "6104942"),
+			table.Entry("a longer SHA of a commit found in a PipelineRun's name", "6104942438", "6104942438"),
+		)
+
+		table.DescribeTable("should reject a --to it can't pin",
+			func(to, message string) {
+				_, err := rollbackCommit(repo, &RollbackOptions{To: to})
+				Expect(err).To(MatchError(ContainSubstring(message)))
+			},
+			table.Entry("shorter than 7 characters", "3f2a9c", "isn't a commit SHA"),
+			table.Entry("not hex", "v1.2.0", "isn't a commit SHA"),
+			table.Entry("a failed run", "1234567", "isn't a successful run of repo/repo"),
+			table.Entry("a run that hasn't finished", "abcdef0", "isn't a successful run of repo/repo"),
+			table.Entry("a commit that never ran", "0000000", "isn't a successful run of repo/repo"),
+			table.Entry("a prefix of more than one run", "9c8b7a6", "matches more than one successful run of repo/repo: 9c8b7a6e4, 9c8b7a6f5"),
+		)
+
+		It("should fail if the Repo has no successful runs", func() {
+			repo = newRepo("", running("3f2a9c1"), failed("1234567"))
+			_, err := rollbackCommit(repo, &RollbackOptions{To: "3f2a9c1"})
+			Expect(err).To(MatchError("repo/repo has no successful runs in its history"))
+		})
+	})
+
+	Describe("rollbackCommit with --previous", func() {
+		table.DescribeTable("should pick the latest successful run of another commit",
+			func(repo *alphav1.Repo, expected string) {
+				sha, err := rollbackCommit(repo, &RollbackOptions{Previous: true})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(sha).To(Equal(expected))
+			},
+			table.Entry("the run before the deployed one",
+				newRepo("3f2a9c1", succeeded("3f2a9c1"), succeeded("9c8b7a6"), succeeded("6104942")), "9c8b7a6"),
+			table.Entry("skipping failed and unfinished runs",
+				newRepo("3f2a9c1", running("abcdef0"), succeeded("3f2a9c1"), failed("1234567"), succeeded("9c8b7a6")), "9c8b7a6"),
+			table.Entry("skipping earlier runs of the deployed commit",
+				newRepo("3f2a9c1", succeeded("3f2a9c1"), succeeded("3f2a9c1"), succeeded("9c8b7a6")), "9c8b7a6"),
+			table.Entry("from a PipelineRun's name",
+				newRepo("3f2a9c1", succeeded("3f2a9c1"), legacy("repo-6104942-x7k2p")), "6104942"),
+			table.Entry("when the deployed commit's run failed",
+				newRepo("3f2a9c1", failed("3f2a9c1"), succeeded("9c8b7a6")), "9c8b7a6"),
+		)
+
+		table.DescribeTable("should fail without a successful run of another commit",
+			func(repo *alphav1.Repo, message string) {
+				_, err := rollbackCommit(repo, &RollbackOptions{Previous: true})
+				Expect(err).To(MatchError(message))
+			},
+			table.Entry("no runs", newRepo(""), "repo/repo has no successful runs in its history"),
+			table.Entry("only failed runs",
+				newRepo("3f2a9c1", failed("3f2a9c1"), failed("9c8b7a6")), "repo/repo has no successful runs in its history"),
+			table.Entry("only runs of the deployed commit",
+				newRepo("3f2a9c1", succeeded("3f2a9c1"), failed("9c8b7a6"), succeeded("3f2a9c1")),
+				"repo/repo has no successful run of a commit other than 3f2a9c1, successful runs: 3f2a9c1, 3f2a9c1"),
+		)
+	})
+
+	Describe("runCommit", func() {
+		table.DescribeTable("should return the commit a run deployed",
+			func(run *alphav1.PipelineStatus, expected string) {
+				Expect(runCommit(newRepo(""), run)).To(Equal(expected))
+			},
+			table.Entry("recorded in the run", succeeded("3f2a9c1"), "3f2a9c1"),
+			table.Entry("recorded in the run over its PipelineRun's name",
+				&alphav1.PipelineStatus{Commit: "3f2a9c1", Ref: &corev1.ObjectReference{Name: "repo-6104942-x7k2p"}}, "3f2a9c1"),
+			table.Entry("in the PipelineRun's name", legacy("repo-6104942-x7k2p"), "6104942"),
+			table.Entry("without a PipelineRun", &alphav1.PipelineStatus{}, ""),
+			table.Entry("with another Repo's PipelineRun", legacy("other-6104942-x7k2p"), ""),
+			table.Entry("with a PipelineRun of a Repo sharing a prefix", legacy("repository-6104942-x7k2p"), ""),
+		)
+	})
+})
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/klog"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+type SuspendOptions struct {
+	Namespace string
+}
+
+var suo = &SuspendOptions{}
+var suspendCmd = &cobra.Command{
+	Use:   "suspend <repo>",
+	Short: "stop deploying a Repo",
+	Long:  "set spec.suspend, so the controller stops looking for new commits and starting PipelineRuns for the Repo. Runs already started carry on.",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := RunSuspend(args[0], true, suo); err != nil {
+			klog.Exit(err)
+		}
+	},
+}
+
+var resumeCmd = &cobra.Command{
+	Use:   "resume <repo>",
+	Short: "start deploying a suspended Repo again",
+	Long:  "clear spec.suspend, so the controller deploys the Repo's latest commit and watches for new ones again",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := RunSuspend(args[0], false, suo); err != nil {
+			klog.Exit(err)
+		}
+	},
+}
+
+// RunSuspend sets the Repo's spec.suspend to suspend
+func RunSuspend(name string, suspend bool, suo *SuspendOptions) error {
+	ctx := context.Background()
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return err
+	}
+
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+
+	repo := &alphav1.Repo{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: suo.Namespace, Name: name}, repo); err != nil {
+		return err
+	}
+
+	patch := client.MergeFrom(repo.DeepCopyObject())
+	repo.Spec.Suspend = suspend
+	if err := c.Patch(ctx, repo, patch); err != nil {
+		return err
+	}
+
+	if suspend {
+		fmt.Printf("repo/%s suspended\n", name)
+		return nil
+	}
+
+	fmt.Printf("repo/%s resumed\n", name)
+	if condition := repo.Status.GetCondition(alphav1.ConditionSuspended); condition != nil && condition.Reason == alphav1.ReasonSuspendedBySelector {
+		fmt.Printf("repo/%s is still suspended: %s\n", name, condition.Message)
+	}
+
+	return nil
+}
+
+func init() {
+	// optional
+	suspendCmd.Flags().StringVarP(&suo.Namespace, "namespace", "", "default", "namespace repo is in")
+	resumeCmd.Flags().StringVarP(&suo.Namespace, "namespace", "", "default", "namespace repo is in")
+
+	rootCmd.AddCommand(suspendCmd)
+	rootCmd.AddCommand(resumeCmd)
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"k8s.io/klog"
+)
+
+var vlo = &LocalOptions{}
+var validateCmd = &cobra.Command{
+	Use:   "validate [dir]",
+	Short: "validate an alaska.yaml",
+	Long:  "check the alaska.yaml of a local checkout, defaulting to the current directory, the way the controller checks it",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+
+		if err := RunValidate(dir, vlo); err != nil {
+			exitConfigErrors(err)
+			klog.Exit(err)
+		}
+	},
+}
+
+func RunValidate(dir string, lo *LocalOptions) error {
+	config, err := loadLocalConfig(dir, lo)
+	if err != nil {
+		return err
+	}
+
+	images, err := localToolImages(config, lo)
+	if err != nil {
+		return err
+	}
+
+	if _, err := config.ToPipelineSpec(images); err != nil {
+		return fmt.Errorf("%s: %v", lo.ConfigPath, err)
+	}
+
+	fmt.Printf("%s is valid\n", lo.ConfigPath)
+	return nil
+}
+
+func init() {
+	// optional
+	addLocalFlags(validateCmd.Flags(), vlo)
+
+	rootCmd.AddCommand(validateCmd)
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2019 Andrew Rudoi.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+)
+
+const (
+	// executorLabel marks the Tasks generated for generic manifests
+	executorLabel = "alpha.alaska.rudeboy.io/executor"
+)
+
+// +kubebuilder:rbac:groups=tekton.dev,resources=tasks,verbs=get;list;watch;create;update;delete
+
+// ensureGenericTasks creates the Tasks the Repo's generic manifests run. Task
+// names are hashes of their spec, so Repos with identical steps share a Task
+// and each of them is listed as an owner. Tasks the Repo no longer uses are
+// released, and deleted once no Repo owns them.
+func (r *RepoReconciler) ensureGenericTasks(ctx context.Context, repo *alphav1.Repo, cfg *alphav1.Config) error {
+	wanted := cfg.GenericTasks()
+
+	for name, spec := range wanted {
+		task := &tektonv1.Task{}
+		err := r.Get(ctx, types.NamespacedName{Namespace: repo.GetNamespace(), Name: name}, task)
+		if apierrors.IsNotFound(err) {
+			task = &tektonv1.Task{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:            name,
+					Namespace:       repo.GetNamespace(),
+					Labels:          map[string]string{executorLabel: string(alphav1.ExecutorGeneric)},
+					OwnerReferences: []metav1.OwnerReference{repoOwnerRef(repo)},
+				},
+				Spec: spec,
+			}
+			if err := r.Create(ctx, task); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if ownerIndex(task, repo) < 0 {
+			task.OwnerReferences = append(task.OwnerReferences, repoOwnerRef(repo))
+			if err := r.Update(ctx, task); err != nil {
+				return err
+			}
+		}
+	}
+
+	tasks := &tektonv1.TaskList{}
+	if err := r.List(ctx, tasks, client.InNamespace(repo.GetNamespace()), client.MatchingLabels{executorLabel: string(alphav1.ExecutorGeneric)}); err != nil {
+		return err
+	}
+
+	for i := range tasks.Items {
+		task := &tasks.Items[i]
+		idx := ownerIndex(task, repo)
+		if _, ok := wanted[task.GetName()]; ok || idx < 0 {
+			continue
+		}
+
+		if len(task.OwnerReferences) == 1 {
+			if err := r.Delete(ctx, task); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+			continue
+		}
+
+		task.OwnerReferences = append(task.OwnerReferences[:idx], task.OwnerReferences[idx+1:]...)
+		if err := r.Update(ctx, task); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// repoOwnerRef is a non-controller owner reference, since a shared Task has
+// no single Repo in charge of it
+func repoOwnerRef(repo *alphav1.Repo) metav1.OwnerReference {
+	ref := *metav1.NewControllerRef(repo, alphav1.GroupVersion.WithKind("Repo"))
+	ref.Controller = nil
+	return ref
+}
+
+func ownerIndex(obj metav1.Object, repo *alphav1.Repo) int {
+	for i, ref := range obj.GetOwnerReferences() {
+		if ref.UID == repo.GetUID() {
+			return i
+		}
+	}
+
+	return -1
+}
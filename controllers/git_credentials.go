@@ -0,0 +1,277 @@
+/*
+Copyright 2019 Andrew Rudoi.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+	"github.com/rudoi/alaska/pkg/alaska"
+	"github.com/rudoi/alaska/pkg/git"
+)
+
+// tektonGitAnnotation tells Tekton's credential initializer which git host a Secret is for
+const tektonGitAnnotation = "tekton.dev/git-0"
+
+// Secrets and ServiceAccounts are read through CredentialsReader, so the
+// controller doesn't need to list or watch them. It creates, updates and
+// deletes the <repo>-git copies in any namespace with a Repo.
+// +kubebuilder:rbac:groups="",resources=secrets;serviceaccounts,verbs=get;create;update;delete
+
+// credentialsReader returns the reader for Secrets and ServiceAccounts
+func (r *RepoReconciler) credentialsReader() client.Reader {
+	if r.CredentialsReader == nil {
+		return r.Client
+	}
+
+	return r.CredentialsReader
+}
+
+// getGitCredentials reads the Secret referenced by spec.credentialsRef, or
+// returns nil if the Repo has none
+func (r *RepoReconciler) getGitCredentials(ctx context.Context, repo *alphav1.Repo) (*git.Credentials, error) {
+	if repo.Spec.CredentialsRef == nil {
+		return nil, nil
+	}
+
+	secret := &corev1.Secret{}
+	query := types.NamespacedName{Namespace: repo.GetNamespace(), Name: repo.Spec.CredentialsRef.Name}
+	if err := r.credentialsReader().Get(ctx, query, secret); err != nil {
+		return nil, err
+	}
+
+	return git.CredentialsFromSecret(secret)
+}
+
+// resolveGitCredentials returns the Repo's own credentials, falling back to a
+// GitHub App installation token. Installation tokens expire, so they're re-read
+// every time.
+func (r *RepoReconciler) resolveGitCredentials(ctx context.Context, repo *alphav1.Repo) (*git.Credentials, error) {
+	creds, err := r.getGitCredentials(ctx, repo)
+	if err != nil || creds != nil {
+		return creds, err
+	}
+
+	return r.Git.AppCredentials(ctx, repo)
+}
+
+// ensureGitCredentials copies creds into a Secret Tekton's git init step
+// understands and attaches it to the ServiceAccount the Repo's PipelineRuns
+// run as. Without creds, both are deleted.
+func (r *RepoReconciler) ensureGitCredentials(ctx context.Context, repo *alphav1.Repo, creds *git.Credentials) error {
+	if creds == nil {
+		return r.deleteGitCredentials(ctx, repo)
+	}
+
+	secret, err := tektonGitSecret(repo, creds)
+	if err != nil {
+		return err
+	}
+
+	existing := &corev1.Secret{}
+	err = r.credentialsReader().Get(ctx, types.NamespacedName{Namespace: secret.GetNamespace(), Name: secret.GetName()}, existing)
+	switch {
+	case apierrors.IsNotFound(err):
+		if err := r.Create(ctx, secret); err != nil {
+			return err
+		}
+	case err != nil:
+		return err
+	case !metav1.IsControlledBy(existing, repo):
+		return fmt.Errorf("secret %s already exists and isn't managed by repo %s, rename or delete it", existing.GetName(), repo.GetName())
+	case existing.Type != secret.Type:
+		// the type of a Secret can't be changed in place
+		if err := r.Delete(ctx, existing); err != nil {
+			return err
+		}
+		if err := r.Create(ctx, secret); err != nil {
+			return err
+		}
+	case !secretUpToDate(existing, secret):
+		existing.Data = secret.Data
+		existing.Labels = mergeStrings(existing.Labels, secret.Labels)
+		existing.Annotations = mergeStrings(existing.Annotations, secret.Annotations)
+		if err := r.Update(ctx, existing); err != nil {
+			return err
+		}
+	}
+
+	sa := &corev1.ServiceAccount{}
+	err = r.credentialsReader().Get(ctx, types.NamespacedName{Namespace: repo.GetNamespace(), Name: alaska.GitCredentialsName(repo)}, sa)
+	if apierrors.IsNotFound(err) {
+		return r.Create(ctx, &corev1.ServiceAccount{
+			ObjectMeta: gitCredentialsObjectMeta(repo),
+			Secrets:    []corev1.ObjectReference{{Name: secret.GetName()}},
+		})
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if !metav1.IsControlledBy(sa, repo) {
+		return fmt.Errorf("serviceaccount %s already exists and isn't managed by repo %s, rename or delete it", sa.GetName(), repo.GetName())
+	}
+
+	if alaska.IsGitCredentials(repo, sa) && hasSecret(sa, secret.GetName()) {
+		return nil
+	}
+
+	// ServiceAccounts created before the label existed get it here
+	sa.Labels = mergeStrings(sa.Labels, map[string]string{alaska.GitCredentialsLabel: repo.GetName()})
+	if !hasSecret(sa, secret.GetName()) {
+		sa.Secrets = append(sa.Secrets, corev1.ObjectReference{Name: secret.GetName()})
+	}
+	return r.Update(ctx, sa)
+}
+
+// secretUpToDate reports whether existing already holds desired's data,
+// labels and annotations
+func secretUpToDate(existing, desired *corev1.Secret) bool {
+	if !reflect.DeepEqual(existing.Data, desired.Data) {
+		return false
+	}
+
+	for k, v := range desired.Labels {
+		if existing.Labels[k] != v {
+			return false
+		}
+	}
+
+	for k, v := range desired.Annotations {
+		if existing.Annotations[k] != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// mergeStrings returns m with the entries of overrides set
+func mergeStrings(m, overrides map[string]string) map[string]string {
+	if m == nil {
+		m = map[string]string{}
+	}
+
+	for k, v := range overrides {
+		m[k] = v
+	}
+
+	return m
+}
+
+// deleteGitCredentials deletes the Repo's copy of its git credentials, after
+// spec.credentialsRef was removed. Objects of the same name the Repo doesn't
+// control are left alone.
+func (r *RepoReconciler) deleteGitCredentials(ctx context.Context, repo *alphav1.Repo) error {
+	query := types.NamespacedName{Namespace: repo.GetNamespace(), Name: alaska.GitCredentialsName(repo)}
+
+	for _, obj := range []runtime.Object{&corev1.ServiceAccount{}, &corev1.Secret{}} {
+		err := r.credentialsReader().Get(ctx, query, obj)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if !metav1.IsControlledBy(obj.(metav1.Object), repo) {
+			continue
+		}
+
+		if err := r.Delete(ctx, obj); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func hasSecret(sa *corev1.ServiceAccount, name string) bool {
+	for _, ref := range sa.Secrets {
+		if ref.Name == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+func tektonGitSecret(repo *alphav1.Repo, creds *git.Credentials) (*corev1.Secret, error) {
+	repository, err := git.ParseURL(repo.Spec.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: gitCredentialsObjectMeta(repo),
+	}
+
+	if creds.IsSSH() {
+		secret.Annotations = map[string]string{tektonGitAnnotation: repository.Host}
+		secret.Type = corev1.SecretTypeSSHAuth
+		secret.Data = map[string][]byte{corev1.SSHAuthPrivateKey: creds.SSHPrivateKey}
+		if len(creds.KnownHosts) > 0 {
+			secret.Data[git.SecretKnownHostsKey] = creds.KnownHosts
+		}
+
+		return secret, nil
+	}
+
+	if repository.Scheme != "http" && repository.Scheme != "https" {
+		return nil, fmt.Errorf("basic auth credentials need an http(s) url, got %q", repo.Spec.URL)
+	}
+
+	username, password := creds.BasicAuth(git.DefaultUsername(git.ProviderFor(repo, repository)))
+	secret.Annotations = map[string]string{tektonGitAnnotation: fmt.Sprintf("%s://%s", repository.Scheme, repository.Host)}
+	secret.Type = corev1.SecretTypeBasicAuth
+	secret.Data = map[string][]byte{
+		corev1.BasicAuthUsernameKey: []byte(username),
+		corev1.BasicAuthPasswordKey: []byte(password),
+	}
+
+	return secret, nil
+}
+
+// gitCredentialsObjectMeta returns ObjectMeta for the Repo's copy of its git
+// credentials
+func gitCredentialsObjectMeta(repo *alphav1.Repo) metav1.ObjectMeta {
+	meta := ownedObjectMeta(repo, alaska.GitCredentialsName(repo))
+	meta.Labels = map[string]string{alaska.GitCredentialsLabel: repo.GetName()}
+	return meta
+}
+
+// ownedObjectMeta returns ObjectMeta for an object named name in the Repo's
+// namespace that is garbage collected with the Repo
+func ownedObjectMeta(repo *alphav1.Repo, name string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      name,
+		Namespace: repo.GetNamespace(),
+		OwnerReferences: []metav1.OwnerReference{
+			*metav1.NewControllerRef(repo, alphav1.GroupVersion.WithKind("Repo")),
+		},
+	}
+}
@@ -0,0 +1,119 @@
+/*
+Copyright 2019 Andrew Rudoi.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+	"github.com/rudoi/alaska/pkg/alaska"
+	"github.com/rudoi/alaska/pkg/git"
+)
+
+var _ = Describe("Git credentials tests", func() {
+	var (
+		ctx   = context.Background()
+		repo  *alphav1.Repo
+		creds = &git.Credentials{Token: "hunter2"}
+		key   = types.NamespacedName{Namespace: "default", Name: "repo-git"}
+	)
+
+	BeforeEach(func() {
+		repo = &alphav1.Repo{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "default", UID: "repo-uid"},
+			Spec:       alphav1.RepoSpec{URL: "https://github.com/rudoi/alaska", Cluster: "production"},
+		}
+	})
+
+	reconciler := func(objs ...runtime.Object) (*RepoReconciler, *fakeClient) {
+		c := newFakeClient(objs...)
+		return &RepoReconciler{Client: c, Log: logf.Log}, c
+	}
+
+	It("should copy the credentials into a labelled Secret and ServiceAccount the Repo controls", func() {
+		r, c := reconciler()
+		Expect(r.ensureGitCredentials(ctx, repo, creds)).To(Succeed())
+
+		secret := &corev1.Secret{}
+		Expect(c.Get(ctx, key, secret)).To(Succeed())
+		Expect(metav1.IsControlledBy(secret, repo)).To(BeTrue())
+		Expect(secret.Labels).To(HaveKeyWithValue(alaska.GitCredentialsLabel, "repo"))
+		Expect(secret.Data).To(HaveKeyWithValue(corev1.BasicAuthPasswordKey, []byte("hunter2")))
+
+		sa := &corev1.ServiceAccount{}
+		Expect(c.Get(ctx, key, sa)).To(Succeed())
+		Expect(alaska.IsGitCredentials(repo, sa)).To(BeTrue())
+		Expect(sa.Secrets).To(ConsistOf(corev1.ObjectReference{Name: "repo-git"}))
+	})
+
+	It("should not write the copies again if nothing changed", func() {
+		r, c := reconciler()
+		Expect(r.ensureGitCredentials(ctx, repo, creds)).To(Succeed())
+		Expect(r.ensureGitCredentials(ctx, repo, creds)).To(Succeed())
+		Expect(c.updates).To(BeZero())
+
+		Expect(r.ensureGitCredentials(ctx, repo, &git.Credentials{Token: "rotated"})).To(Succeed())
+		Expect(c.updates).To(Equal(1))
+	})
+
+	It("should leave a Secret of the same name the Repo doesn't control alone", func() {
+		own := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo-git", Namespace: "default"},
+			Type:       corev1.SecretTypeOpaque,
+			Data:       map[string][]byte{git.SecretTokenKey: []byte("mine")},
+		}
+		r, c := reconciler(own)
+
+		err := r.ensureGitCredentials(ctx, repo, creds)
+		Expect(err).To(MatchError(ContainSubstring("isn't managed by repo repo")))
+
+		secret := &corev1.Secret{}
+		Expect(c.Get(ctx, key, secret)).To(Succeed())
+		Expect(secret.Type).To(Equal(corev1.SecretTypeOpaque))
+		Expect(secret.Data).To(Equal(own.Data))
+	})
+
+	It("should not take over a ServiceAccount of the same name", func() {
+		r, c := reconciler(&corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "repo-git", Namespace: "default"}})
+
+		err := r.ensureGitCredentials(ctx, repo, creds)
+		Expect(err).To(MatchError(ContainSubstring("isn't managed by repo repo")))
+
+		sa := &corev1.ServiceAccount{}
+		Expect(c.Get(ctx, key, sa)).To(Succeed())
+		Expect(sa.Labels).To(BeEmpty())
+		Expect(sa.Secrets).To(BeEmpty())
+	})
+
+	It("should delete the copies once the Repo has no credentials", func() {
+		r, c := reconciler()
+		Expect(r.ensureGitCredentials(ctx, repo, creds)).To(Succeed())
+		Expect(r.ensureGitCredentials(ctx, repo, nil)).To(Succeed())
+
+		Expect(apierrors.IsNotFound(c.Get(ctx, key, &corev1.Secret{}))).To(BeTrue())
+		Expect(apierrors.IsNotFound(c.Get(ctx, key, &corev1.ServiceAccount{}))).To(BeTrue())
+	})
+})
@@ -18,39 +18,70 @@ package controllers
 
 import (
 	"context"
-	"encoding/base64"
-	"net/url"
-	"strings"
+	"fmt"
+	"sync"
 	"time"
 
-	"github.com/google/go-github/v28/github"
-	yaml "gopkg.in/yaml.v2"
-
 	"github.com/go-logr/logr"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
 	knative "knative.dev/pkg/apis"
 
 	alphav1 "github.com/rudoi/alaska/api/v1"
 	"github.com/rudoi/alaska/pkg/alaska"
+	"github.com/rudoi/alaska/pkg/git"
 )
 
 // RepoReconciler reconciles a Repo object
 type RepoReconciler struct {
 	client.Client
-	GitHub *github.Client
-	Log    logr.Logger
+	Git *git.Factory
+	Log logr.Logger
+
+	// Events, if set, queues Repos for reconcile outside of the sync period, e.g. on a push
+	Events <-chan event.GenericEvent
+
+	// SyncInterval is how often a Repo's branch is read from git. Reconciles
+	// caused by its PipelineRuns and Pipeline in between only refresh run
+	// status. If zero, the branch is read on every reconcile.
+	SyncInterval time.Duration
+
+	// ToolImages resolves the tool versions in alaska.yaml to executor images
+	ToolImages alphav1.ToolImages
+
+	// Recorder, if set, records an Event when a Repo's PipelineRun is started and when it finishes
+	Recorder record.EventRecorder
+
+	// CredentialsReader, if set, reads Secrets and ServiceAccounts straight
+	// from the API server instead of through the manager's cache, which would
+	// list and watch every Secret in the cluster
+	CredentialsReader client.Reader
+
+	// SuspendSelector, if set, suspends every Repo whose labels match it as
+	// if it set spec.suspend
+	SuspendSelector labels.Selector
+
+	// pushed holds the Repos queued by Events that haven't synced since
+	pushed sync.Map
 }
 
 // +kubebuilder:rbac:groups=alpha.alaska.rudeboy.io,resources=repos,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=alpha.alaska.rudeboy.io,resources=repos/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 // +kubebuilder:rbac:groups=tekton.dev,resources=pipelineresources;taskruns,verbs=get;list;watch;create;update;delete
+// +kubebuilder:rbac:groups=tekton.dev,resources=pipelines;pipelineruns,verbs=get;list;watch;create;update;patch;delete
 
 func (r *RepoReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	ctx := context.Background()
@@ -68,122 +99,356 @@ func (r *RepoReconciler) Reconcile(req ctrl.Request) (ctrl.Result, error) {
 	patch := client.MergeFrom(repo.DeepCopyObject())
 
 	defer func() {
+		repo.Status.ObservedGeneration = repo.GetGeneration()
+		repo.Status.SetReady()
+
 		if err := r.Status().Patch(ctx, repo, patch); err != nil && !apierrors.IsNotFound(err) {
 			log.Error(err, "error patching status")
 		}
 	}()
 
+	// suspended Repos only keep their runs' status up to date
+	wasSuspended := repo.Status.IsSuspended()
+	if reason, message := r.suspended(repo); reason != "" {
+		log.V(4).Info("repo is suspended", "reason", reason)
+		repo.Status.SetSuspended(reason, message)
+		return r.reconcileRuns(ctx, repo)
+	}
+	repo.Status.SetSuspended("", "")
+
+	// commits pushed while suspended weren't looked at, so resuming syncs straight away
+	if !wasSuspended && !r.syncDue(req.NamespacedName, repo) {
+		result, err := r.reconcileRuns(ctx, repo)
+		if err != nil || repo.Status.QueuedCommit == "" || len(unfinishedRuns(repo)) > 0 {
+			return result, err
+		}
+
+		log.Info("runs finished, deploying queued commit", "commit", repo.Status.QueuedCommit)
+	}
+
 	if err := r.ensureTektonGitResource(ctx, repo); err != nil {
 		log.Error(err, "unable to ensure PipelineResource")
+		repo.Status.SetCondition(alphav1.ConditionConfigValid, corev1.ConditionFalse, alphav1.ReasonPipelineError, err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.Git.Validate(repo); err != nil {
+		log.Info("invalid repo url", "url", repo.Spec.URL, "error", err.Error())
+		repo.Status.SetCondition(alphav1.ConditionFetched, corev1.ConditionFalse, alphav1.ReasonInvalidURL, err.Error())
 		return ctrl.Result{}, nil
 	}
 
-	url, err := url.Parse(repo.Spec.URL)
+	creds, err := r.resolveGitCredentials(ctx, repo)
 	if err != nil {
-		return ctrl.Result{}, err
+		log.Error(err, "unable to get git credentials")
+		repo.Status.SetCondition(alphav1.ConditionFetched, corev1.ConditionFalse, alphav1.ReasonCredentialsError, err.Error())
+		return ctrl.Result{}, nil
 	}
 
-	owner := strings.Split(url.Path, "/")[1]
-	repoName := strings.TrimSuffix(strings.Split(url.Path, "/")[2], ".git")
+	if err := r.ensureGitCredentials(ctx, repo, creds); err != nil {
+		log.Error(err, "unable to ensure git credentials for pipeline")
+		repo.Status.SetCondition(alphav1.ConditionFetched, corev1.ConditionFalse, alphav1.ReasonCredentialsError, err.Error())
+		return ctrl.Result{}, nil
+	}
 
-	branch, _, err := r.GitHub.Repositories.GetBranch(ctx, owner, repoName, repo.Spec.Branch)
+	provider, err := r.Git.ForRepo(repo, creds)
 	if err != nil {
-		log.Error(err, "failed to get branch")
+		log.Error(err, "unable to get git provider")
+		repo.Status.SetCondition(alphav1.ConditionFetched, corev1.ConditionFalse, alphav1.ReasonInvalidURL, err.Error())
 		return ctrl.Result{}, nil
 	}
 
-	sha := branch.GetCommit().GetSHA()[:7]
-	content, _, _, err := r.GitHub.Repositories.GetContents(ctx, owner, repoName, "alaska.yaml", &github.RepositoryContentGetOptions{Ref: sha})
-	if err != nil {
+	// a pinned revision is deployed instead of the branch head. The CRD and
+	// webhook check it too, but neither covers Repos created before them.
+	head := repo.Spec.Revision
+	if head != "" && !alphav1.IsCommitSHA(head) {
+		log.Info("invalid revision", "revision", head)
+		repo.Status.SetCondition(alphav1.ConditionFetched, corev1.ConditionFalse, alphav1.ReasonInvalidRevision,
+			fmt.Sprintf("spec.revision %q isn't a commit SHA of 7 to 40 hex characters", head))
+		return ctrl.Result{}, nil
+	}
+
+	if head == "" {
+		head, err = provider.Head(ctx, repo.Spec.Branch)
+		if err == nil && len(head) < 7 {
+			err = fmt.Errorf("branch %s resolved to %q, not a commit SHA", repo.Spec.Branch, head)
+		}
+		if err != nil {
+			log.Error(err, "failed to get branch")
+			repo.Status.SetCondition(alphav1.ConditionFetched, corev1.ConditionFalse, alphav1.ReasonBranchLookupFailed, err.Error())
+			return ctrl.Result{}, nil
+		}
+	}
+
+	sha := head[:7]
+	read := func(path string) ([]byte, error) {
+		return provider.ReadFile(ctx, head, path)
+	}
+
+	config, err := alaska.LoadConfig(read, repo.GetConfigPath())
+	if _, ok := err.(*alaska.FetchError); ok {
 		log.Error(err, "unable to get config")
+		repo.Status.SetCondition(alphav1.ConditionFetched, corev1.ConditionFalse, alphav1.ReasonConfigFetchFailed, err.Error())
 		return ctrl.Result{}, nil
 	}
 
-	decodedConfig, err := base64.StdEncoding.DecodeString(*content.Content)
-	if err != nil {
-		log.Error(err, "unable to decode config file")
+	now := metav1.Now()
+	repo.Status.LastSyncTime = &now
+	fetched := fmt.Sprintf("fetched %s at %s", repo.GetConfigPath(), sha)
+	if repo.Spec.Revision != "" {
+		fetched += " (pinned by spec.revision)"
+	}
+	repo.Status.SetCondition(alphav1.ConditionFetched, corev1.ConditionTrue, alphav1.ReasonFetched, fetched)
+
+	if _, ok := err.(*alaska.ValidationError); ok {
+		log.Error(err, "invalid config")
+		repo.Status.SetCondition(alphav1.ConditionConfigValid, corev1.ConditionFalse, alphav1.ReasonInvalidConfig, err.Error())
 		return ctrl.Result{}, nil
 	}
 
-	config := &alphav1.Config{}
-	if err := yaml.Unmarshal(decodedConfig, config); err != nil {
-		log.Error(err, "unable to unmarshal config")
+	if err != nil {
+		log.Error(err, "unable to load config")
+		repo.Status.SetCondition(alphav1.ConditionConfigValid, corev1.ConditionFalse, alphav1.ReasonParseError, err.Error())
 		return ctrl.Result{}, nil
 	}
 
 	repo.Status.Config = config
 
-	if err := r.ensurePipelineForRepo(ctx, repo, config); err != nil {
+	spec, err := config.ToPipelineSpec(r.ToolImages)
+	if err != nil {
+		log.Error(err, "invalid config")
+		repo.Status.SetCondition(alphav1.ConditionConfigValid, corev1.ConditionFalse, alphav1.ReasonInvalidConfig, err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.ensureGenericTasks(ctx, repo, config); err != nil {
+		log.Error(err, "unable to ensure generic executor tasks for repo")
+		repo.Status.SetCondition(alphav1.ConditionConfigValid, corev1.ConditionFalse, alphav1.ReasonPipelineError, err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	if err := r.ensurePipelineForRepo(ctx, repo, spec); err != nil {
 		log.Error(err, "unable to ensure pipeline for repo")
+		repo.Status.SetCondition(alphav1.ConditionConfigValid, corev1.ConditionFalse, alphav1.ReasonPipelineError, err.Error())
 		return ctrl.Result{}, nil
 	}
 
+	repo.Status.SetCondition(alphav1.ConditionConfigValid, corev1.ConditionTrue, alphav1.ReasonValid, "")
+
 	log.V(4).Info("incoming config", "config", config)
 
+	repo.Status.QueuedCommit = ""
 	if repo.Status.CommitSHA != sha {
-		log.Info("new commit detected", "branch", "master", "old", repo.Status.CommitSHA, "new", sha)
+		log.Info("new commit detected", "branch", repo.Spec.Branch, "revision", repo.Spec.Revision, "old", repo.Status.CommitSHA, "new", sha)
+
+		// runs that finished since they were last looked at mustn't hold up
+		// the queue or be cancelled
+		if _, err := r.reconcileRuns(ctx, repo); err != nil {
+			return ctrl.Result{}, err
+		}
+
+		if running := unfinishedRuns(repo); len(running) > 0 {
+			switch repo.GetConcurrencyPolicy() {
+			case alphav1.ConcurrencyQueue:
+				log.Info("queueing new commit behind unfinished runs", "commit", sha, "run", running[0].Ref.Name)
+				repo.Status.QueuedCommit = sha
+				setDeployingCondition(repo)
+				return ctrl.Result{}, nil
+			case alphav1.ConcurrencyCancelPrevious:
+				if err := r.cancelRuns(ctx, repo, running, sha); err != nil {
+					repo.Status.SetCondition(alphav1.ConditionDeploying, corev1.ConditionFalse, alphav1.ReasonTriggerFailed, err.Error())
+					return ctrl.Result{}, err
+				}
+			}
+		}
+
 		repo.Status.CommitSHA = sha
 
 		if err := r.patchGitResource(ctx, repo, sha); err != nil {
+			repo.Status.SetCondition(alphav1.ConditionDeploying, corev1.ConditionFalse, alphav1.ReasonTriggerFailed, err.Error())
 			return ctrl.Result{}, err
 		}
 
-		if err := alaska.TriggerPipeline(ctx, r.Client, repo, config, sha); err != nil {
+		// TriggerPipeline looks up the <repo>-git ServiceAccount, which isn't cached
+		c := client.DelegatingClient{Reader: r.credentialsReader(), Writer: r.Client, StatusClient: r.Client}
+		if err := alaska.TriggerPipeline(ctx, c, repo, config, sha); err != nil {
+			repo.Status.SetCondition(alphav1.ConditionDeploying, corev1.ConditionFalse, alphav1.ReasonTriggerFailed, err.Error())
 			return ctrl.Result{}, err
 		}
+		r.event(repo, corev1.EventTypeNormal, "Triggered", "started PipelineRun %s for %s", repo.Status.Runs[0].Ref.Name, sha)
 	}
 
-	for i := range repo.Status.Runs {
-		if !repo.Status.Runs[i].Completed {
-			r.Log.Info("pizza time, run has NOT completed", "run", repo.Status.Runs[i])
-			if err := r.updatePipelineRunStatus(ctx, repo, repo.Status.Runs[i], sha); err != nil {
-				log.Error(err, "error waiting for pipeline to succeed")
-				return ctrl.Result{}, nil
-			}
+	return r.reconcileRuns(ctx, repo)
+}
 
-			if repo.Status.Runs[i].Status == "Failed" {
-				log.Info("pipeline failed, check the logs")
-				repo.Status.Runs[i].Completed = true
-				continue
-			}
+// reconcileRuns copies the status of every unfinished PipelineRun into
+// Status.Runs. It's called whenever Tekton updates a PipelineRun the Repo owns.
+func (r *RepoReconciler) reconcileRuns(ctx context.Context, repo *alphav1.Repo) (ctrl.Result, error) {
+	log := r.Log.WithValues("repo", types.NamespacedName{Namespace: repo.GetNamespace(), Name: repo.GetName()})
+
+	for _, run := range repo.Status.Runs {
+		if run.Completed || run.Ref == nil {
+			continue
+		}
 
-			if !repo.Status.Runs[i].Succeeded {
-				log.Info("waiting for pipeline to succeed")
-				return ctrl.Result{RequeueAfter: 3 * time.Second}, nil
+		if err := r.updatePipelineRunStatus(ctx, run); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return ctrl.Result{}, err
 			}
 
-			log.Info("pipeline for commit succeeded", "commit", sha)
-			repo.Status.Runs[i].Completed = true
+			log.Info("pipeline run was deleted before it completed", "run", run.Ref.Name)
+			run.Completed = true
+			continue
+		}
+
+		switch {
+		case !run.Completed:
+			log.V(4).Info("waiting for pipeline to complete", "run", run.Ref.Name)
+		case run.SupersededBy != "":
+			log.Info("pipeline cancelled", "run", run.Ref.Name, "supersededBy", run.SupersededBy)
+			r.event(repo, corev1.EventTypeNormal, "Cancelled", "PipelineRun %s was cancelled, superseded by %s", run.Ref.Name, run.SupersededBy)
+		case run.Succeeded:
+			log.Info("pipeline succeeded", "run", run.Ref.Name)
+			r.event(repo, corev1.EventTypeNormal, alphav1.ReasonSucceeded, "PipelineRun %s succeeded", run.Ref.Name)
+		default:
+			log.Info("pipeline failed, see its logs with akctl logs", "run", run.Ref.Name, "status", run.Status,
+				"command", fmt.Sprintf("akctl logs %s --namespace %s", repo.GetName(), repo.GetNamespace()))
+			r.event(repo, corev1.EventTypeWarning, alphav1.ReasonFailed, "PipelineRun %s failed (%s)", run.Ref.Name, run.Status)
 		}
 	}
 
+	setDeployingCondition(repo)
 	return ctrl.Result{}, nil
 }
 
+// event records an Event about the Repo if the reconciler has a Recorder
+func (r *RepoReconciler) event(repo *alphav1.Repo, eventType, reason, messageFmt string, args ...interface{}) {
+	if r.Recorder != nil {
+		r.Recorder.Eventf(repo, eventType, reason, messageFmt, args...)
+	}
+}
+
+// suspended returns why the Repo is suspended, or an empty reason if it isn't
+func (r *RepoReconciler) suspended(repo *alphav1.Repo) (string, string) {
+	if repo.Spec.Suspend {
+		return alphav1.ReasonSuspended, "spec.suspend is set"
+	}
+
+	if r.SuspendSelector != nil && r.SuspendSelector.Matches(labels.Set(repo.GetLabels())) {
+		return alphav1.ReasonSuspendedBySelector, fmt.Sprintf("labels match the controller's suspend selector %q", r.SuspendSelector.String())
+	}
+
+	return "", ""
+}
+
+// unfinishedRuns returns the Repo's runs that haven't completed, latest first
+func unfinishedRuns(repo *alphav1.Repo) []*alphav1.PipelineStatus {
+	running := []*alphav1.PipelineStatus{}
+	for _, run := range repo.Status.Runs {
+		if !run.Completed && run.Ref != nil {
+			running = append(running, run)
+		}
+	}
+
+	return running
+}
+
+// cancelRuns cancels the PipelineRuns of runs, which sha supersedes. Tekton
+// stops their TaskRuns and marks them failed.
+func (r *RepoReconciler) cancelRuns(ctx context.Context, repo *alphav1.Repo, runs []*alphav1.PipelineStatus, sha string) error {
+	for _, run := range runs {
+		pipelineRun := &tektonv1.PipelineRun{}
+		if err := r.Get(ctx, types.NamespacedName{Namespace: run.Ref.Namespace, Name: run.Ref.Name}, pipelineRun); err != nil {
+			if apierrors.IsNotFound(err) {
+				continue
+			}
+			return err
+		}
+
+		patch := client.MergeFrom(pipelineRun.DeepCopyObject())
+		pipelineRun.Spec.Status = tektonv1.PipelineRunSpecStatusCancelled
+		if err := r.Patch(ctx, pipelineRun, patch); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+
+		r.Log.Info("cancelled superseded pipeline", "repo", repo.GetName(), "run", run.Ref.Name, "supersededBy", sha)
+		run.SupersededBy = sha
+	}
+
+	return nil
+}
+
+// setDeployingCondition reports the state of the latest PipelineRun
+func setDeployingCondition(repo *alphav1.Repo) {
+	if len(repo.Status.Runs) == 0 || repo.Status.Runs[0].Ref == nil {
+		return
+	}
+
+	if running := unfinishedRuns(repo); repo.Status.QueuedCommit != "" && len(running) > 0 {
+		repo.Status.SetCondition(alphav1.ConditionDeploying, corev1.ConditionTrue, alphav1.ReasonQueued,
+			fmt.Sprintf("%s is queued behind PipelineRun %s", repo.Status.QueuedCommit, running[0].Ref.Name))
+		return
+	}
+
+	latest := repo.Status.Runs[0]
+	switch {
+	case !latest.Completed:
+		repo.Status.SetCondition(alphav1.ConditionDeploying, corev1.ConditionTrue, alphav1.ReasonRunning,
+			fmt.Sprintf("PipelineRun %s is running", latest.Ref.Name))
+	case latest.Succeeded:
+		repo.Status.SetCondition(alphav1.ConditionDeploying, corev1.ConditionFalse, alphav1.ReasonSucceeded,
+			fmt.Sprintf("PipelineRun %s succeeded", latest.Ref.Name))
+	default:
+		repo.Status.SetCondition(alphav1.ConditionDeploying, corev1.ConditionFalse, alphav1.ReasonFailed,
+			fmt.Sprintf("PipelineRun %s failed (%s), see its logs with akctl logs %s --namespace %s",
+				latest.Ref.Name, latest.Status, repo.GetName(), repo.GetNamespace()))
+	}
+}
+
+// syncDue returns true if the Repo's branch should be read from git: on a
+// push, when the spec changed, or when SyncInterval has passed since the last sync
+func (r *RepoReconciler) syncDue(key types.NamespacedName, repo *alphav1.Repo) bool {
+	if _, ok := r.pushed.Load(key); ok {
+		r.pushed.Delete(key)
+		return true
+	}
+
+	if r.SyncInterval == 0 || repo.Status.LastSyncTime == nil || repo.Status.ObservedGeneration != repo.GetGeneration() {
+		return true
+	}
+
+	// resyncs arrive roughly every SyncInterval, anything older than half of it is due
+	return time.Since(repo.Status.LastSyncTime.Time) >= r.SyncInterval/2
+}
+
 func (r *RepoReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&alphav1.Repo{}).
-		Complete(r)
+		Owns(&tektonv1.Pipeline{}).
+		Owns(&tektonv1.PipelineRun{})
+
+	if r.Events != nil {
+		events := make(chan event.GenericEvent)
+		go func() {
+			defer close(events)
+			for evt := range r.Events {
+				r.pushed.Store(types.NamespacedName{Namespace: evt.Meta.GetNamespace(), Name: evt.Meta.GetName()}, true)
+				events <- evt
+			}
+		}()
+
+		builder = builder.Watches(&source.Channel{Source: events}, &handler.EnqueueRequestForObject{})
+	}
+
+	return builder.Complete(r)
 }
 
 func (r *RepoReconciler) ensureTektonGitResource(ctx context.Context, repo *alphav1.Repo) error {
 	resource := &tektonv1.PipelineResource{}
 	if err := r.Get(ctx, types.NamespacedName{Namespace: repo.GetNamespace(), Name: repo.GetName()}, resource); err != nil {
 		if apierrors.IsNotFound(err) {
-			r.Log.Info("")
 			newResource := &tektonv1.PipelineResource{
-				ObjectMeta: metav1.ObjectMeta{
-					Name:      repo.GetName(),
-					Namespace: repo.GetNamespace(),
-					OwnerReferences: []metav1.OwnerReference{
-						{
-							APIVersion: repo.APIVersion,
-							Kind:       repo.Kind,
-							Name:       repo.GetName(),
-							UID:        repo.GetUID(),
-						},
-					},
-				},
+				ObjectMeta: ownedObjectMeta(repo, repo.GetName()),
 				Spec: tektonv1.PipelineResourceSpec{
 					Type: tektonv1.PipelineResourceTypeGit,
 					Params: []tektonv1.ResourceParam{
@@ -241,7 +506,7 @@ func (r *RepoReconciler) patchGitResource(ctx context.Context, repo *alphav1.Rep
 	return nil
 }
 
-func (r *RepoReconciler) updatePipelineRunStatus(ctx context.Context, repo *alphav1.Repo, runStatus *alphav1.PipelineStatus, sha string) error {
+func (r *RepoReconciler) updatePipelineRunStatus(ctx context.Context, runStatus *alphav1.PipelineStatus) error {
 	query := types.NamespacedName{
 		Namespace: runStatus.Ref.Namespace,
 		Name:      runStatus.Ref.Name,
@@ -264,12 +529,13 @@ func (r *RepoReconciler) updatePipelineRunStatus(ctx context.Context, repo *alph
 		if condition.Type == knative.ConditionSucceeded {
 			runStatus.Status = condition.Reason
 			runStatus.Succeeded = condition.IsTrue()
+			runStatus.Completed = !condition.IsUnknown()
 		}
 	}
 	return nil
 }
 
-func (r *RepoReconciler) ensurePipelineForRepo(ctx context.Context, repo *alphav1.Repo, cfg *alphav1.Config) error {
+func (r *RepoReconciler) ensurePipelineForRepo(ctx context.Context, repo *alphav1.Repo, spec tektonv1.PipelineSpec) error {
 	query := types.NamespacedName{
 		Namespace: repo.GetNamespace(),
 		Name:      repo.GetName(),
@@ -280,11 +546,8 @@ func (r *RepoReconciler) ensurePipelineForRepo(ctx context.Context, repo *alphav
 	if apierrors.IsNotFound(err) {
 		// create
 		pipeline = &tektonv1.Pipeline{
-			ObjectMeta: metav1.ObjectMeta{
-				Namespace: repo.GetNamespace(),
-				Name:      repo.GetName(),
-			},
-			Spec: cfg.ToPipelineSpec(),
+			ObjectMeta: ownedObjectMeta(repo, repo.GetName()),
+			Spec:       spec,
 		}
 
 		return r.Create(ctx, pipeline)
@@ -294,7 +557,12 @@ func (r *RepoReconciler) ensurePipelineForRepo(ctx context.Context, repo *alphav
 		return err
 	}
 
-	// update
-	pipeline.Spec = cfg.ToPipelineSpec()
+	// update, only if something changed so the Pipeline watch doesn't requeue us for nothing
+	if equality.Semantic.DeepEqual(pipeline.Spec, spec) && metav1.IsControlledBy(pipeline, repo) {
+		return nil
+	}
+
+	pipeline.Spec = spec
+	pipeline.OwnerReferences = ownedObjectMeta(repo, repo.GetName()).OwnerReferences
 	return r.Update(ctx, pipeline)
 }
@@ -0,0 +1,285 @@
+/*
+Copyright 2019 Andrew Rudoi.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	knative "knative.dev/pkg/apis"
+	duckv1beta1 "knative.dev/pkg/apis/duck/v1beta1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+	"github.com/rudoi/alaska/pkg/git"
+)
+
+const (
+	firstCommit  = "6104942438c14ec7bd21c6cd5bd995272b3faff6"
+	secondCommit = "3f2a9c1d0e8b7a6f5e4d3c2b1a0f9e8d7c6b5a49"
+	thirdCommit  = "9c8b7a6f5e4d3c2b1a0f9e8d7c6b5a493f2a9c1d"
+)
+
+// These tests call Reconcile with a fake client, reading the Repo's branch
+// from a fake GitLab whose head they move.
+var _ = Describe("Repo controller tests", func() {
+	var (
+		ctx    = context.Background()
+		key    = types.NamespacedName{Namespace: "default", Name: "repo"}
+		server *httptest.Server
+		head   string
+		repo   *alphav1.Repo
+		r      *RepoReconciler
+		c      *fakeClient
+	)
+
+	BeforeEach(func() {
+		head = firstCommit
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/api/v4/projects/", func(w http.ResponseWriter, req *http.Request) {
+			switch req.URL.EscapedPath() {
+			case "/api/v4/projects/group%2Fmanifests/repository/branches/master":
+				_, _ = w.Write([]byte(`{"name":"master","commit":{"id":"` + head + `"}}`))
+			case "/api/v4/projects/group%2Fmanifests/repository/files/alaska.yaml/raw":
+				_, _ = w.Write([]byte("manifests:\n- path: deploy.yaml\n"))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		})
+		server = httptest.NewServer(mux)
+
+		repo = &alphav1.Repo{
+			ObjectMeta: metav1.ObjectMeta{Name: key.Name, Namespace: key.Namespace, UID: "repo-uid"},
+			Spec: alphav1.RepoSpec{
+				URL:      server.URL + "/group/manifests",
+				Provider: alphav1.ProviderGitLab,
+				Branch:   "master",
+				Cluster:  "production",
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	start := func() {
+		c = newFakeClient(repo)
+		r = &RepoReconciler{Client: c, Git: &git.Factory{}, Log: logf.Log}
+	}
+
+	reconcile := func() *alphav1.Repo {
+		_, err := r.Reconcile(ctrl.Request{NamespacedName: key})
+		Expect(err).NotTo(HaveOccurred())
+
+		latest := &alphav1.Repo{}
+		Expect(c.Get(ctx, key, latest)).To(Succeed())
+		return latest
+	}
+
+	pipelineRuns := func() []tektonv1.PipelineRun {
+		list := &tektonv1.PipelineRunList{}
+		Expect(c.List(ctx, list, client.InNamespace(key.Namespace))).To(Succeed())
+		return list.Items
+	}
+
+	finish := func(name string) {
+		pipelineRun := &tektonv1.PipelineRun{}
+		Expect(c.Get(ctx, types.NamespacedName{Namespace: key.Namespace, Name: name}, pipelineRun)).To(Succeed())
+		pipelineRun.Status.Conditions = duckv1beta1.Conditions{
+			{Type: knative.ConditionSucceeded, Status: corev1.ConditionTrue, Reason: "Succeeded"},
+		}
+		Expect(c.Update(ctx, pipelineRun)).To(Succeed())
+	}
+
+	Context("with the queue concurrency policy", func() {
+		BeforeEach(func() {
+			repo.Spec.ConcurrencyPolicy = alphav1.ConcurrencyQueue
+			start()
+		})
+
+		It("should queue a new commit until the running PipelineRun finishes", func() {
+			latest := reconcile()
+			Expect(latest.Status.CommitSHA).To(Equal(firstCommit[:7]))
+			Expect(pipelineRuns()).To(HaveLen(1))
+			first := latest.Status.Runs[0].Ref.Name
+
+			head = secondCommit
+			latest = reconcile()
+			Expect(latest.Status.QueuedCommit).To(Equal(secondCommit[:7]))
+			Expect(latest.Status.CommitSHA).To(Equal(firstCommit[:7]))
+			Expect(latest.Status.GetCondition(alphav1.ConditionDeploying).Reason).To(Equal(alphav1.ReasonQueued))
+			Expect(pipelineRuns()).To(HaveLen(1))
+
+			finish(first)
+			latest = reconcile()
+			Expect(latest.Status.QueuedCommit).To(BeEmpty())
+			Expect(latest.Status.CommitSHA).To(Equal(secondCommit[:7]))
+			Expect(latest.Status.Runs).To(HaveLen(2))
+			Expect(latest.Status.Runs[0].Commit).To(Equal(secondCommit[:7]))
+			Expect(latest.Status.Runs[1].Succeeded).To(BeTrue())
+			Expect(pipelineRuns()).To(HaveLen(2))
+		})
+
+		It("should queue only the latest commit", func() {
+			reconcile()
+
+			head = secondCommit
+			reconcile()
+			head = thirdCommit
+			latest := reconcile()
+			Expect(latest.Status.QueuedCommit).To(Equal(thirdCommit[:7]))
+			Expect(pipelineRuns()).To(HaveLen(1))
+		})
+
+		It("should not hold a new commit for a run that finished since the last sync", func() {
+			latest := reconcile()
+			finish(latest.Status.Runs[0].Ref.Name)
+
+			head = secondCommit
+			latest = reconcile()
+			Expect(latest.Status.QueuedCommit).To(BeEmpty())
+			Expect(latest.Status.CommitSHA).To(Equal(secondCommit[:7]))
+			Expect(latest.Status.Runs[1].Completed).To(BeTrue())
+			Expect(pipelineRuns()).To(HaveLen(2))
+		})
+	})
+
+	Context("with the cancel previous concurrency policy", func() {
+		BeforeEach(func() {
+			repo.Spec.ConcurrencyPolicy = alphav1.ConcurrencyCancelPrevious
+			start()
+		})
+
+		It("should cancel the running PipelineRun and start the new commit", func() {
+			latest := reconcile()
+			first := latest.Status.Runs[0].Ref.Name
+
+			head = secondCommit
+			latest = reconcile()
+			Expect(latest.Status.CommitSHA).To(Equal(secondCommit[:7]))
+			Expect(latest.Status.Runs).To(HaveLen(2))
+			Expect(latest.Status.Runs[1].Ref.Name).To(Equal(first))
+			Expect(latest.Status.Runs[1].SupersededBy).To(Equal(secondCommit[:7]))
+
+			cancelled := &tektonv1.PipelineRun{}
+			Expect(c.Get(ctx, types.NamespacedName{Namespace: key.Namespace, Name: first}, cancelled)).To(Succeed())
+			Expect(cancelled.Spec.Status).To(BeEquivalentTo(tektonv1.PipelineRunSpecStatusCancelled))
+			Expect(pipelineRuns()).To(HaveLen(2))
+		})
+
+		It("should not cancel a run that finished since the last sync", func() {
+			latest := reconcile()
+			first := latest.Status.Runs[0].Ref.Name
+			finish(first)
+
+			head = secondCommit
+			latest = reconcile()
+			Expect(latest.Status.Runs[1].SupersededBy).To(BeEmpty())
+			Expect(latest.Status.Runs[1].Succeeded).To(BeTrue())
+
+			finished := &tektonv1.PipelineRun{}
+			Expect(c.Get(ctx, types.NamespacedName{Namespace: key.Namespace, Name: first}, finished)).To(Succeed())
+			Expect(finished.Spec.Status).To(BeEmpty())
+		})
+	})
+
+	Context("with the allow concurrency policy", func() {
+		BeforeEach(func() {
+			repo.Spec.ConcurrencyPolicy = alphav1.ConcurrencyAllow
+			start()
+		})
+
+		It("should start the new commit alongside the running PipelineRun", func() {
+			reconcile()
+
+			head = secondCommit
+			latest := reconcile()
+			Expect(latest.Status.CommitSHA).To(Equal(secondCommit[:7]))
+			Expect(latest.Status.Runs).To(HaveLen(2))
+			Expect(latest.Status.Runs[1].Completed).To(BeFalse())
+			Expect(latest.Status.Runs[1].SupersededBy).To(BeEmpty())
+		})
+	})
+
+	Context("suspending", func() {
+		BeforeEach(func() {
+			start()
+		})
+
+		update := func(change func(repo *alphav1.Repo)) {
+			latest := &alphav1.Repo{}
+			Expect(c.Get(ctx, key, latest)).To(Succeed())
+			change(latest)
+			Expect(c.Update(ctx, latest)).To(Succeed())
+		}
+
+		It("should not start PipelineRuns for a Repo with spec.suspend set", func() {
+			update(func(repo *alphav1.Repo) { repo.Spec.Suspend = true })
+
+			latest := reconcile()
+			Expect(latest.Status.IsSuspended()).To(BeTrue())
+			Expect(latest.Status.GetCondition(alphav1.ConditionSuspended).Reason).To(Equal(alphav1.ReasonSuspended))
+			Expect(latest.Status.CommitSHA).To(BeEmpty())
+			Expect(pipelineRuns()).To(BeEmpty())
+		})
+
+		It("should not start PipelineRuns for a Repo the suspend selector matches", func() {
+			r.SuspendSelector = labels.SelectorFromSet(labels.Set{"freeze": "true"})
+			update(func(repo *alphav1.Repo) { repo.Labels = map[string]string{"freeze": "true"} })
+
+			latest := reconcile()
+			Expect(latest.Status.GetCondition(alphav1.ConditionSuspended).Reason).To(Equal(alphav1.ReasonSuspendedBySelector))
+			Expect(pipelineRuns()).To(BeEmpty())
+
+			update(func(repo *alphav1.Repo) { repo.Labels = nil })
+			latest = reconcile()
+			Expect(latest.Status.IsSuspended()).To(BeFalse())
+			Expect(latest.Status.CommitSHA).To(Equal(firstCommit[:7]))
+			Expect(pipelineRuns()).To(HaveLen(1))
+		})
+
+		It("should deploy the branch head on resume without waiting for a sync", func() {
+			r.SyncInterval = time.Hour
+			reconcile()
+
+			update(func(repo *alphav1.Repo) { repo.Spec.Suspend = true })
+			head = secondCommit
+			latest := reconcile()
+			Expect(latest.Status.CommitSHA).To(Equal(firstCommit[:7]))
+			Expect(pipelineRuns()).To(HaveLen(1))
+
+			update(func(repo *alphav1.Repo) { repo.Spec.Suspend = false })
+			latest = reconcile()
+			Expect(latest.Status.GetCondition(alphav1.ConditionSuspended).Reason).To(Equal(alphav1.ReasonResumed))
+			Expect(latest.Status.CommitSHA).To(Equal(secondCommit[:7]))
+			Expect(pipelineRuns()).To(HaveLen(2))
+		})
+	})
+})
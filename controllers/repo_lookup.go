@@ -0,0 +1,47 @@
+/*
+Copyright 2019 Andrew Rudoi.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+)
+
+// RepoReconciler answers the Repo webhooks' questions with the same
+// providers and credentials it reconciles with
+var _ alphav1.RepoLookup = &RepoReconciler{}
+
+// DefaultBranch returns the default branch of the Repo's repository
+func (r *RepoReconciler) DefaultBranch(ctx context.Context, repo *alphav1.Repo) (string, error) {
+	creds, err := r.resolveGitCredentials(ctx, repo)
+	if err != nil {
+		return "", err
+	}
+
+	provider, err := r.Git.ForRepo(repo, creds)
+	if err != nil {
+		return "", err
+	}
+
+	return provider.DefaultBranch(ctx)
+}
+
+// ValidateURL returns an error if the Repo's provider can't read spec.url
+func (r *RepoReconciler) ValidateURL(repo *alphav1.Repo) error {
+	return r.Git.Validate(repo)
+}
@@ -0,0 +1,181 @@
+/*
+Copyright 2019 Andrew Rudoi.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+)
+
+type fakeLookup struct {
+	branch    string
+	branchErr error
+	urlErr    error
+}
+
+func (f *fakeLookup) DefaultBranch(ctx context.Context, repo *alphav1.Repo) (string, error) {
+	return f.branch, f.branchErr
+}
+
+func (f *fakeLookup) ValidateURL(repo *alphav1.Repo) error {
+	return f.urlErr
+}
+
+// The webhooks are served by the manager started in suite_test.go, so these
+// tests go through API server admission like any client would.
+var _ = Describe("Repo webhook tests", func() {
+	var (
+		ctx       = context.Background()
+		repo      *alphav1.Repo
+		resources []*tektonv1.PipelineResource
+	)
+
+	BeforeEach(func() {
+		*lookup = fakeLookup{branch: "main"}
+
+		resources = []*tektonv1.PipelineResource{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "production", Namespace: "default"},
+				Spec:       tektonv1.PipelineResourceSpec{Type: tektonv1.PipelineResourceTypeCluster},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "source", Namespace: "default"},
+				Spec:       tektonv1.PipelineResourceSpec{Type: tektonv1.PipelineResourceTypeGit},
+			},
+		}
+		for _, resource := range resources {
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+		}
+
+		repo = &alphav1.Repo{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo-sample", Namespace: "default"},
+			Spec: alphav1.RepoSpec{
+				URL:     "https://github.com/rudoi/alaska",
+				Cluster: "production",
+			},
+		}
+	})
+
+	AfterEach(func() {
+		for _, resource := range resources {
+			if err := k8sClient.Delete(ctx, resource); err != nil {
+				Expect(apierrors.IsNotFound(err)).To(BeTrue())
+			}
+		}
+		if err := k8sClient.Delete(ctx, repo); err != nil {
+			Expect(apierrors.IsNotFound(err)).To(BeTrue())
+		}
+	})
+
+	Context("defaulting", func() {
+		It("should default the branch to the repository's default branch", func() {
+			Expect(k8sClient.Create(ctx, repo)).To(Succeed())
+			Expect(repo.Spec.Branch).To(Equal("main"))
+			Expect(repo.Spec.ConfigPath).To(Equal(alphav1.DefaultConfigPath))
+			Expect(repo.Spec.ConcurrencyPolicy).To(Equal(alphav1.ConcurrencyAllow))
+		})
+
+		It("should keep a branch that's set", func() {
+			repo.Spec.Branch = "release"
+			Expect(k8sClient.Create(ctx, repo)).To(Succeed())
+			Expect(repo.Spec.Branch).To(Equal("release"))
+		})
+
+		It("should fall back to master if the default branch can't be looked up", func() {
+			lookup.branchErr = errors.New("not found")
+			Expect(k8sClient.Create(ctx, repo)).To(Succeed())
+			Expect(repo.Spec.Branch).To(Equal(alphav1.FallbackBranch))
+		})
+	})
+
+	Context("validating creates", func() {
+		BeforeEach(func() {
+			repo.Spec.Branch = "master"
+		})
+
+		It("should accept a Repo deploying to a cluster PipelineResource", func() {
+			Expect(k8sClient.Create(ctx, repo)).To(Succeed())
+		})
+
+		It("should reject a cluster without a PipelineResource", func() {
+			repo.Spec.Cluster = "staging"
+			err := k8sClient.Create(ctx, repo)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`spec.cluster: Not found: "staging"`))
+		})
+
+		It("should reject a PipelineResource that isn't a cluster", func() {
+			repo.Spec.Cluster = "source"
+			err := k8sClient.Create(ctx, repo)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("PipelineResource is of type git, not cluster"))
+		})
+
+		It("should reject a URL the provider can't read", func() {
+			lookup.urlErr = errors.New("invalid github url")
+			err := k8sClient.Create(ctx, repo)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.url: Invalid value"))
+		})
+
+		It("should accept a commit SHA as revision", func() {
+			repo.Spec.Revision = "3f2a9c1"
+			Expect(k8sClient.Create(ctx, repo)).To(Succeed())
+		})
+
+		It("should reject a revision that isn't a commit SHA", func() {
+			repo.Spec.Revision = "v1.2.0"
+			err := k8sClient.Create(ctx, repo)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.revision"))
+		})
+
+		It("should reject a URL git would read as an option", func() {
+			repo.Spec.URL = "--upload-pack=touch /tmp/x"
+			err := k8sClient.Create(ctx, repo)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring("spec.url"))
+		})
+	})
+
+	Context("validating updates", func() {
+		It("should validate the updated Repo", func() {
+			Expect(k8sClient.Create(ctx, repo)).To(Succeed())
+
+			repo.Spec.Cluster = "staging"
+			err := k8sClient.Update(ctx, repo)
+			Expect(apierrors.IsInvalid(err)).To(BeTrue())
+			Expect(err.Error()).To(ContainSubstring(`spec.cluster: Not found: "staging"`))
+		})
+
+		It("should allow updates to a Repo whose cluster was deleted if the cluster isn't changed", func() {
+			Expect(k8sClient.Create(ctx, repo)).To(Succeed())
+			Expect(k8sClient.Delete(ctx, resources[0])).To(Succeed())
+
+			repo.Spec.Suspend = true
+			Expect(k8sClient.Update(ctx, repo)).To(Succeed())
+		})
+	})
+})
@@ -17,16 +17,41 @@ limitations under the License.
 package controllers
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 
+	jsonpatch "github.com/evanphx/json-patch"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	alphav1 "github.com/rudoi/alaska/api/v1"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	admissionv1beta1 "k8s.io/api/admissionregistration/v1beta1"
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
 	"sigs.k8s.io/controller-runtime/pkg/envtest"
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
@@ -40,6 +65,14 @@ var cfg *rest.Config
 var k8sClient client.Client
 var testEnv *envtest.Environment
 
+// lookup answers the Repo webhooks' questions, tests set what it returns
+var lookup = &fakeLookup{}
+
+var (
+	certDir     string
+	stopManager chan struct{}
+)
+
 func TestAPIs(t *testing.T) {
 	RegisterFailHandler(Fail)
 
@@ -53,7 +86,9 @@ var _ = BeforeSuite(func(done Done) {
 
 	By("bootstrapping test environment")
 	testEnv = &envtest.Environment{
-		CRDDirectoryPaths: []string{filepath.Join("..", "config", "crd", "bases")},
+		CRDDirectoryPaths:  []string{filepath.Join("..", "config", "crd", "bases")},
+		CRDs:               []*apiextensionsv1beta1.CustomResourceDefinition{pipelineResourceCRD},
+		KubeAPIServerFlags: webhookAPIServerFlags(),
 	}
 
 	var err error
@@ -64,17 +99,250 @@ var _ = BeforeSuite(func(done Done) {
 	err = alphav1.AddToScheme(scheme.Scheme)
 	Expect(err).NotTo(HaveOccurred())
 
+	err = tektonv1.AddToScheme(scheme.Scheme)
+	Expect(err).NotTo(HaveOccurred())
+
 	// +kubebuilder:scaffold:scheme
 
 	k8sClient, err = client.New(cfg, client.Options{Scheme: scheme.Scheme})
 	Expect(err).ToNot(HaveOccurred())
 	Expect(k8sClient).ToNot(BeNil())
 
+	By("serving the Repo webhooks")
+	certDir, err = ioutil.TempDir("", "alaska-webhook-test-")
+	Expect(err).NotTo(HaveOccurred())
+	caBundle, err := writeServingCert(certDir)
+	Expect(err).NotTo(HaveOccurred())
+
+	port, err := freePort()
+	Expect(err).NotTo(HaveOccurred())
+
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{Scheme: scheme.Scheme, MetricsBindAddress: "0", Host: "127.0.0.1", Port: port})
+	Expect(err).NotTo(HaveOccurred())
+	mgr.GetWebhookServer().CertDir = certDir
+	Expect((&alphav1.RepoWebhook{Lookup: lookup}).SetupWithManager(mgr)).To(Succeed())
+
+	stopManager = make(chan struct{})
+	go func() {
+		defer GinkgoRecover()
+		Expect(mgr.Start(stopManager)).To(Succeed())
+	}()
+
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	Eventually(func() error {
+		conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+		if err == nil {
+			conn.Close()
+		}
+		return err
+	}, 10*time.Second).Should(Succeed())
+
+	Expect(installWebhooks(k8sClient, "https://"+addr, caBundle)).To(Succeed())
+
 	close(done)
 }, 60)
 
 var _ = AfterSuite(func() {
 	By("tearing down the test environment")
+	if stopManager != nil {
+		close(stopManager)
+	}
+	os.RemoveAll(certDir)
+
 	err := testEnv.Stop()
 	Expect(err).ToNot(HaveOccurred())
 })
+
+// fakeClient is a fake client for tests that call the reconciler directly.
+// It counts updates and names objects created with a generateName, which
+// controller-runtime's fake client doesn't.
+type fakeClient struct {
+	client.Client
+	updates   int
+	generated int
+}
+
+func newFakeClient(objs ...runtime.Object) *fakeClient {
+	Expect(alphav1.AddToScheme(scheme.Scheme)).To(Succeed())
+	Expect(tektonv1.AddToScheme(scheme.Scheme)).To(Succeed())
+
+	return &fakeClient{Client: fake.NewFakeClientWithScheme(scheme.Scheme, objs...)}
+}
+
+func (c *fakeClient) Create(ctx context.Context, obj runtime.Object, opts ...client.CreateOption) error {
+	if meta, ok := obj.(metav1.Object); ok && meta.GetName() == "" && meta.GetGenerateName() != "" {
+		c.generated++
+		meta.SetName(fmt.Sprintf("%s%d", meta.GetGenerateName(), c.generated))
+	}
+
+	return c.Client.Create(ctx, obj, opts...)
+}
+
+func (c *fakeClient) Update(ctx context.Context, obj runtime.Object, opts ...client.UpdateOption) error {
+	c.updates++
+	return c.Client.Update(ctx, obj, opts...)
+}
+
+// Patch applies merge patches itself: the fake client unmarshals the patched
+// object over the old one, so fields the patch removes are kept
+func (c *fakeClient) Patch(ctx context.Context, obj runtime.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.MergePatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+
+	data, err := patch.Data(obj)
+	if err != nil {
+		return err
+	}
+
+	key, err := client.ObjectKeyFromObject(obj)
+	if err != nil {
+		return err
+	}
+
+	current := obj.DeepCopyObject()
+	if err := c.Client.Get(ctx, key, current); err != nil {
+		return err
+	}
+
+	old, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+
+	patched, err := jsonpatch.MergePatch(old, data)
+	if err != nil {
+		return err
+	}
+
+	value := reflect.ValueOf(obj).Elem()
+	value.Set(reflect.Zero(value.Type()))
+	if err := json.Unmarshal(patched, obj); err != nil {
+		return err
+	}
+
+	return c.Client.Update(ctx, obj)
+}
+
+func (c *fakeClient) Status() client.StatusWriter {
+	return c
+}
+
+// webhookAPIServerFlags are envtest's API server flags with the admission
+// webhook plugins enabled instead of AlwaysAdmit
+func webhookAPIServerFlags() []string {
+	flags := []string{}
+	for _, flag := range envtest.DefaultKubeAPIServerFlags {
+		if flag == "--admission-control=AlwaysAdmit" {
+			flag = "--admission-control=MutatingAdmissionWebhook,ValidatingAdmissionWebhook"
+		}
+		flags = append(flags, flag)
+	}
+
+	return flags
+}
+
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// writeServingCert writes a self-signed certificate for 127.0.0.1 where the
+// webhook server looks for it, returning it PEM encoded as the CA bundle
+func writeServingCert(dir string) ([]byte, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := ioutil.WriteFile(filepath.Join(dir, "tls.crt"), cert, 0600); err != nil {
+		return nil, err
+	}
+
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	if err := ioutil.WriteFile(filepath.Join(dir, "tls.key"), keyPEM, 0600); err != nil {
+		return nil, err
+	}
+
+	return cert, nil
+}
+
+// installWebhooks creates the webhook configurations the controller deploys,
+// pointed at the webhook server on url instead of the in-cluster Service
+func installWebhooks(c client.Client, url string, caBundle []byte) error {
+	manifests, err := ioutil.ReadFile(filepath.Join("..", "config", "webhook", "manifests.yaml"))
+	if err != nil {
+		return err
+	}
+
+	pointAt := func(webhooks []admissionv1beta1.Webhook) {
+		for i := range webhooks {
+			hookURL := url + *webhooks[i].ClientConfig.Service.Path
+			webhooks[i].ClientConfig = admissionv1beta1.WebhookClientConfig{URL: &hookURL, CABundle: caBundle}
+		}
+	}
+
+	for _, doc := range bytes.Split(manifests, []byte("\n---\n")) {
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(doc, nil, nil)
+		if err != nil {
+			return err
+		}
+
+		switch config := obj.(type) {
+		case *admissionv1beta1.MutatingWebhookConfiguration:
+			pointAt(config.Webhooks)
+		case *admissionv1beta1.ValidatingWebhookConfiguration:
+			pointAt(config.Webhooks)
+		}
+
+		if err := c.Create(context.Background(), obj); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// pipelineResourceCRD stands in for Tekton's PipelineResource CRD, which
+// isn't vendored
+var pipelineResourceCRD = &apiextensionsv1beta1.CustomResourceDefinition{
+	ObjectMeta: metav1.ObjectMeta{Name: "pipelineresources.tekton.dev"},
+	Spec: apiextensionsv1beta1.CustomResourceDefinitionSpec{
+		Group:   tektonv1.SchemeGroupVersion.Group,
+		Version: tektonv1.SchemeGroupVersion.Version,
+		Scope:   apiextensionsv1beta1.NamespaceScoped,
+		Names: apiextensionsv1beta1.CustomResourceDefinitionNames{
+			Plural:   "pipelineresources",
+			Singular: "pipelineresource",
+			Kind:     "PipelineResource",
+			ListKind: "PipelineResourceList",
+		},
+	},
+}
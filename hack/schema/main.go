@@ -0,0 +1,191 @@
+/*
+Copyright 2019 Andrew Rudoi.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// schema prints the JSON Schema of alaska.yaml, for editors to complete and
+// check config files with
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"strings"
+
+	"k8s.io/klog"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+)
+
+// enums are the values of the string types alaska.yaml only accepts some of
+var enums = map[reflect.Type][]string{
+	reflect.TypeOf(alphav1.Strategy("")): {
+		string(alphav1.StrategyDefault),
+		string(alphav1.StrategySequential),
+	},
+	reflect.TypeOf(alphav1.Executor("")): {
+		string(alphav1.ExecutorDefault),
+		string(alphav1.ExecutorHelm),
+		string(alphav1.ExecutorKustomize),
+		string(alphav1.ExecutorGeneric),
+	},
+	reflect.TypeOf(alphav1.Tool("")): {
+		string(alphav1.ToolKubectl),
+		string(alphav1.ToolHelm),
+		string(alphav1.ToolKustomize),
+	},
+}
+
+type generator struct {
+	// docs holds field doc comments, keyed by Type.Field
+	docs        map[string]string
+	definitions map[string]interface{}
+}
+
+func main() {
+	apiDir := flag.String("api-dir", "api/v1", "directory of the api/v1 package, for doc comments")
+	flag.Parse()
+
+	docs, err := fieldDocs(*apiDir)
+	if err != nil {
+		klog.Exitf("unable to read doc comments: %v", err)
+	}
+
+	g := &generator{docs: docs, definitions: map[string]interface{}{}}
+	schema := g.object(reflect.TypeOf(alphav1.Config{}))
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "alaska.yaml"
+	schema["definitions"] = g.definitions
+
+	out, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		klog.Exitf("unable to marshal schema: %v", err)
+	}
+
+	os.Stdout.Write(append(out, '\n'))
+}
+
+// schema returns the schema of t, defining structs other than the config itself
+func (g *generator) schema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	if values, ok := enums[t]; ok {
+		return map[string]interface{}{"type": "string", "enum": values}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Slice:
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		if values, ok := enums[t.Key()]; ok {
+			properties := map[string]interface{}{}
+			for _, value := range values {
+				properties[value] = g.schema(t.Elem())
+			}
+			return map[string]interface{}{"type": "object", "properties": properties, "additionalProperties": false}
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if _, ok := g.definitions[t.Name()]; !ok {
+			// reserve the name first, so recursive types terminate
+			g.definitions[t.Name()] = nil
+			g.definitions[t.Name()] = g.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/definitions/" + t.Name()}
+	default:
+		return map[string]interface{}{"type": "string"}
+	}
+}
+
+// object returns the schema of the struct t, with the field names yaml.v2 reads
+func (g *generator) object(t reflect.Type) map[string]interface{} {
+	properties := map[string]interface{}{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		name := strings.Split(field.Tag.Get("yaml"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		property := g.schema(field.Type)
+		if doc := g.docs[t.Name()+"."+field.Name]; doc != "" {
+			property["description"] = doc
+		}
+		properties[name] = property
+	}
+
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// fieldDocs returns the doc comments of the struct fields in dir
+func fieldDocs(dir string) (map[string]string, error) {
+	pkgs, err := parser.ParseDir(token.NewFileSet(), dir, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := map[string]string{}
+	for _, pkg := range pkgs {
+		ast.Inspect(pkg, func(node ast.Node) bool {
+			spec, ok := node.(*ast.TypeSpec)
+			if !ok {
+				return true
+			}
+
+			structType, ok := spec.Type.(*ast.StructType)
+			if !ok {
+				return false
+			}
+
+			for _, field := range structType.Fields.List {
+				if field.Doc == nil {
+					continue
+				}
+
+				lines := []string{}
+				for _, line := range strings.Split(field.Doc.Text(), "\n") {
+					// leave out kubebuilder markers
+					if line != "" && !strings.HasPrefix(line, "+") {
+						lines = append(lines, line)
+					}
+				}
+
+				for _, name := range field.Names {
+					docs[spec.Name.Name+"."+name.Name] = strings.Join(lines, " ")
+				}
+			}
+
+			return false
+		})
+	}
+
+	return docs, nil
+}
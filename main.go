@@ -17,22 +17,26 @@ limitations under the License.
 package main
 
 import (
-	"context"
 	"flag"
+	"io/ioutil"
 	"os"
 	"time"
 
-	"github.com/google/go-github/v28/github"
 	alphav1 "github.com/rudoi/alaska/api/v1"
 	"github.com/rudoi/alaska/controllers"
+	"github.com/rudoi/alaska/pkg/git"
+	"github.com/rudoi/alaska/pkg/push"
 	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
 	"golang.org/x/oauth2"
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	_ "k8s.io/client-go/plugin/pkg/client/auth/gcp"
 	"k8s.io/klog"
 	"k8s.io/klog/klogr"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -54,19 +58,85 @@ func init() {
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
+	var pushAddr string
+	var syncPeriod time.Duration
+	var githubAppID int64
+	var githubAppKey string
+	var githubBaseURL string
+	var githubUploadURL string
+	var toolImagesPath string
+	var enableWebhooks bool
+	var suspendSelector string
 	flag.StringVar(&metricsAddr, "metrics-addr", ":8080", "The address the metric endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "enable-leader-election", false,
 		"Enable leader election for controller manager. Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&pushAddr, "push-addr", "",
+		"The address the GitHub push event receiver binds to. Requires GITHUB_WEBHOOK_SECRET. Disabled if empty.")
+	flag.DurationVar(&syncPeriod, "sync-period", 10*time.Second,
+		"How often every Repo is polled for new commits. Defaults to 5m when the push receiver is enabled.")
+	flag.Int64Var(&githubAppID, "github-app-id", 0,
+		"ID of a GitHub App to authenticate as instead of GITHUB_TOKEN. Requires --github-app-private-key.")
+	flag.StringVar(&githubAppKey, "github-app-private-key", "", "Path to the PEM encoded private key of the GitHub App.")
+	flag.StringVar(&githubBaseURL, "github-base-url", "",
+		"GitHub Enterprise Server API base URL, e.g. https://github.example.com/api/v3/. Used for Repos on hosts other than github.com.")
+	flag.StringVar(&githubUploadURL, "github-upload-url", "", "GitHub Enterprise Server upload API base URL. Defaults to --github-base-url.")
+	flag.StringVar(&toolImagesPath, "tool-images", "",
+		"Path to a YAML file mapping kubectl, helm and kustomize versions to executor images. Without it, alaska.yaml can't pin tool versions.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", true,
+		"Serve the Repo defaulting and validating webhooks. Needs a serving certificate in /tmp/k8s-webhook-server/serving-certs, disable to run outside the cluster.")
+	flag.StringVar(&suspendSelector, "suspend-selector", "",
+		"Label selector of Repos to suspend as if they set spec.suspend, e.g. during a maintenance window. Disabled if empty.")
 	flag.Parse()
 
+	if pushAddr != "" && !isFlagSet("sync-period") {
+		// pushes drive reconciles, polling is only a fallback for missed deliveries
+		syncPeriod = 5 * time.Minute
+	}
+
 	ctrl.SetLogger(klogr.New())
 
 	ts := oauth2.StaticTokenSource(
 		&oauth2.Token{AccessToken: os.Getenv("GITHUB_TOKEN")},
 	)
-	tc := oauth2.NewClient(context.Background(), ts)
 
-	syncPeriod := 10 * time.Second
+	var githubApp *git.GitHubApp
+	if githubAppID != 0 {
+		key, err := ioutil.ReadFile(githubAppKey)
+		if err != nil {
+			setupLog.Error(err, "unable to read github app private key")
+			os.Exit(1)
+		}
+
+		if githubApp, err = git.NewGitHubApp(githubAppID, key, githubBaseURL); err != nil {
+			setupLog.Error(err, "unable to configure github app")
+			os.Exit(1)
+		}
+	}
+
+	toolImages := alphav1.ToolImages{}
+	if toolImagesPath != "" {
+		data, err := ioutil.ReadFile(toolImagesPath)
+		if err != nil {
+			setupLog.Error(err, "unable to read tool images")
+			os.Exit(1)
+		}
+
+		if err := yaml.Unmarshal(data, &toolImages); err != nil {
+			setupLog.Error(err, "unable to parse tool images")
+			os.Exit(1)
+		}
+	}
+
+	var suspend labels.Selector
+	if suspendSelector != "" {
+		selector, err := labels.Parse(suspendSelector)
+		if err != nil {
+			setupLog.Error(err, "unable to parse suspend selector")
+			os.Exit(1)
+		}
+		suspend = selector
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:             scheme,
 		MetricsBindAddress: metricsAddr,
@@ -78,14 +148,56 @@ func main() {
 		os.Exit(1)
 	}
 
-	if err = (&controllers.RepoReconciler{
-		Client: mgr.GetClient(),
-		GitHub: github.NewClient(tc),
-		Log:    ctrl.Log.WithName("controllers").WithName("Repo"),
-	}).SetupWithManager(mgr); err != nil {
+	var events chan event.GenericEvent
+	if pushAddr != "" {
+		secret := os.Getenv("GITHUB_WEBHOOK_SECRET")
+		if secret == "" {
+			setupLog.Info("GITHUB_WEBHOOK_SECRET must be set to enable the push receiver")
+			os.Exit(1)
+		}
+
+		// the receiver drops pushes rather than wait for the controller
+		events = make(chan event.GenericEvent, 100)
+		if err := mgr.Add(&push.Receiver{
+			Client: mgr.GetClient(),
+			Log:    ctrl.Log.WithName("push"),
+			Addr:   pushAddr,
+			Secret: []byte(secret),
+			Events: events,
+		}); err != nil {
+			setupLog.Error(err, "unable to add push receiver")
+			os.Exit(1)
+		}
+	}
+
+	reconciler := &controllers.RepoReconciler{
+		Client:            mgr.GetClient(),
+		CredentialsReader: mgr.GetAPIReader(),
+		Git: &git.Factory{
+			GitHubToken:     ts,
+			GitHubApp:       githubApp,
+			GitHubBaseURL:   githubBaseURL,
+			GitHubUploadURL: githubUploadURL,
+			GitLabToken:     os.Getenv("GITLAB_TOKEN"),
+		},
+		Log:             ctrl.Log.WithName("controllers").WithName("Repo"),
+		Events:          events,
+		Recorder:        mgr.GetEventRecorderFor("alaska"),
+		SuspendSelector: suspend,
+		SyncInterval:    syncPeriod,
+		ToolImages:      toolImages,
+	}
+	if err = reconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Repo")
 		os.Exit(1)
 	}
+
+	if enableWebhooks {
+		if err = (&alphav1.RepoWebhook{Lookup: reconciler}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Repo")
+			os.Exit(1)
+		}
+	}
 	// +kubebuilder:scaffold:builder
 
 	setupLog.Info("starting manager")
@@ -94,3 +206,13 @@ func main() {
 		os.Exit(1)
 	}
 }
+
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
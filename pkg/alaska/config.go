@@ -0,0 +1,317 @@
+package alaska
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+)
+
+// ReadFunc reads a file from the repo at the commit being deployed
+type ReadFunc func(path string) ([]byte, error)
+
+// FetchError is returned by LoadConfig when a config file can't be read, as
+// opposed to being invalid
+type FetchError struct {
+	Path string
+	Err  error
+}
+
+func (e *FetchError) Error() string {
+	return fmt.Sprintf("unable to read %s: %v", e.Path, e.Err)
+}
+
+// ConfigError is a problem with a config file, at the line and column of the
+// field it's about when they're known
+type ConfigError struct {
+	File   string
+	Line   int
+	Column int
+
+	// Field is the path of the field within File, e.g. manifests[0].path
+	Field   string
+	Message string
+}
+
+func (e *ConfigError) Error() string {
+	pos := e.File
+	if e.Line > 0 {
+		pos = fmt.Sprintf("%s:%d:%d", e.File, e.Line, e.Column)
+	}
+
+	if e.Field != "" {
+		return fmt.Sprintf("%s: %s: %s", pos, e.Field, e.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", pos, e.Message)
+}
+
+// ParseError is returned by LoadConfig when a config file isn't YAML, has
+// fields a Config doesn't or includes itself
+type ParseError struct {
+	Errors []*ConfigError
+}
+
+func (e *ParseError) Error() string {
+	return joinErrors(e.Errors)
+}
+
+// ValidationError is returned by LoadConfig when the merged config doesn't
+// pass Config.Validate
+type ValidationError struct {
+	Errors []*ConfigError
+}
+
+func (e *ValidationError) Error() string {
+	return joinErrors(e.Errors)
+}
+
+func joinErrors(errs []*ConfigError) string {
+	msgs := []string{}
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// LoadConfig reads the config at configPath and merges in the files it
+// includes, recursively. Included files are merged before the file including
+// them, so its own settings win. A file included more than once is only merged
+// the first time, and a file including itself, directly or not, is an error.
+// Unknown fields are errors, and the merged config must pass Validate.
+func LoadConfig(read ReadFunc, configPath string) (*alphav1.Config, error) {
+	l := &configLoader{
+		read:    read,
+		loaded:  map[string]bool{},
+		sources: map[string][]byte{},
+		origins: map[interface{}]origin{},
+	}
+
+	config, err := l.load(cleanPath(configPath), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if errs := config.Validate(); len(errs) > 0 {
+		return nil, l.validationError(config, errs)
+	}
+
+	return config, nil
+}
+
+// origin is the file a manifest or stage was read from and its field path there
+type origin struct {
+	file  string
+	field string
+}
+
+type configLoader struct {
+	read   ReadFunc
+	loaded map[string]bool
+
+	// sources holds every file read, and merged lists them in the order
+	// they were merged in
+	sources map[string][]byte
+	merged  []string
+
+	// origins is keyed by *ManifestOptions and *Stage
+	origins map[interface{}]origin
+}
+
+// load reads file, which is included through the files in stack
+func (l *configLoader) load(file string, stack []string) (*alphav1.Config, error) {
+	stack = append(stack, file)
+	l.loaded[file] = true
+
+	data, err := l.read(file)
+	if err != nil {
+		return nil, &FetchError{Path: file, Err: err}
+	}
+	l.sources[file] = data
+
+	own := &alphav1.Config{}
+	if err := yaml.UnmarshalStrict(data, own); err != nil {
+		return nil, &ParseError{Errors: yamlErrors(file, data, err)}
+	}
+
+	for i, manifest := range own.Manifests {
+		l.origins[manifest] = origin{file: file, field: fmt.Sprintf("manifests[%d]", i)}
+	}
+	for i, stage := range own.Stages {
+		l.origins[stage] = origin{file: file, field: fmt.Sprintf("stages[%d]", i)}
+	}
+
+	merged := &alphav1.Config{}
+	for i, include := range own.Include {
+		includePath := cleanPath(include)
+		if !strings.HasPrefix(include, "/") {
+			includePath = cleanPath(path.Join(path.Dir(file), include))
+		}
+
+		if containsPath(stack, includePath) {
+			cycle := strings.Join(append(stack, includePath), " -> ")
+			return nil, &ParseError{Errors: []*ConfigError{
+				l.configError(file, fmt.Sprintf("include[%d]", i), "include cycle: "+cycle),
+			}}
+		}
+
+		// a file that's not on the stack but loaded was merged already
+		if l.loaded[includePath] {
+			continue
+		}
+
+		included, err := l.load(includePath, stack)
+		if err != nil {
+			return nil, err
+		}
+		mergeConfig(merged, included)
+	}
+
+	mergeConfig(merged, own)
+	merged.Include = nil
+	l.merged = append(l.merged, file)
+
+	return merged, nil
+}
+
+// configError returns an error about fldPath in file, at its position if
+// it can be found
+func (l *configLoader) configError(file, fldPath, message string) *ConfigError {
+	line, col := position(l.sources[file], fldPath)
+	return &ConfigError{File: file, Line: line, Column: col, Field: fldPath, Message: message}
+}
+
+// validationError reports errs, which are about the merged config, against
+// the files the fields were read from
+func (l *configLoader) validationError(config *alphav1.Config, errs field.ErrorList) *ValidationError {
+	// manifests and stages are merged in as they are, so their path in the
+	// merged config maps to one in their file
+	origins := map[string]origin{}
+	for i, manifest := range config.Manifests {
+		origins[fmt.Sprintf("manifests[%d]", i)] = l.origins[manifest]
+	}
+	for i, stage := range config.Stages {
+		origins[fmt.Sprintf("stages[%d]", i)] = l.origins[stage]
+	}
+
+	verr := &ValidationError{}
+	for _, err := range errs {
+		verr.Errors = append(verr.Errors, l.locate(origins, err))
+	}
+
+	return verr
+}
+
+// locate returns err as an error about the file the field it's about was read from
+func (l *configLoader) locate(origins map[string]origin, err *field.Error) *ConfigError {
+	for prefix, o := range origins {
+		if err.Field == prefix || strings.HasPrefix(err.Field, prefix+".") || strings.HasPrefix(err.Field, prefix+"[") {
+			return l.configError(o.file, o.field+strings.TrimPrefix(err.Field, prefix), err.ErrorBody())
+		}
+	}
+
+	// other fields come from the last file merged that sets them
+	for i := len(l.merged) - 1; i >= 0; i-- {
+		file := l.merged[i]
+		if line, _ := position(l.sources[file], err.Field); line > 0 {
+			return l.configError(file, err.Field, err.ErrorBody())
+		}
+	}
+
+	return &ConfigError{File: l.merged[len(l.merged)-1], Field: err.Field, Message: err.ErrorBody()}
+}
+
+var (
+	yamlLineRegexp  = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	yamlFieldRegexp = regexp.MustCompile(`^field (\S+) not found in type \S+$`)
+	yamlKeyRegexp   = regexp.MustCompile(`^(?:field (\S+) already set in type \S+|key "(.*)" already set in map)$`)
+)
+
+// yamlErrors turns an error from yaml.UnmarshalStrict into ConfigErrors,
+// adding the column of unknown and duplicate fields
+func yamlErrors(file string, data []byte, err error) []*ConfigError {
+	msgs := []string{err.Error()}
+	if typeErr, ok := err.(*yaml.TypeError); ok {
+		msgs = typeErr.Errors
+	}
+
+	lines := strings.Split(string(data), "\n")
+	errs := []*ConfigError{}
+	for _, msg := range msgs {
+		match := yamlLineRegexp.FindStringSubmatch(msg)
+		if match == nil {
+			errs = append(errs, &ConfigError{File: file, Message: strings.TrimPrefix(msg, "yaml: ")})
+			continue
+		}
+
+		configErr := &ConfigError{File: file, Message: match[2]}
+		configErr.Line, _ = strconv.Atoi(match[1])
+
+		name := ""
+		if m := yamlFieldRegexp.FindStringSubmatch(match[2]); m != nil {
+			name = m[1]
+			configErr.Message = fmt.Sprintf("unknown field %q", name)
+		} else if m := yamlKeyRegexp.FindStringSubmatch(match[2]); m != nil {
+			name = m[1] + m[2]
+			configErr.Message = fmt.Sprintf("duplicate field %q", name)
+		}
+
+		if configErr.Line > 0 && configErr.Line <= len(lines) {
+			text := lines[configErr.Line-1]
+			configErr.Column = len(text) - len(strings.TrimLeft(text, " ")) + 1
+			if i := strings.Index(text, name); name != "" && i >= 0 {
+				configErr.Column = i + 1
+			}
+		}
+
+		errs = append(errs, configErr)
+	}
+
+	return errs
+}
+
+// mergeConfig merges src into dst. Manifests and stages are appended, and
+// anything else src sets replaces dst's.
+func mergeConfig(dst, src *alphav1.Config) {
+	dst.Manifests = append(dst.Manifests, src.Manifests...)
+	dst.Stages = append(dst.Stages, src.Stages...)
+
+	if src.Strategy != "" {
+		dst.Strategy = src.Strategy
+	}
+
+	if src.Namespace != "" {
+		dst.Namespace = src.Namespace
+	}
+
+	dst.CreateNamespace = dst.CreateNamespace || src.CreateNamespace
+
+	for tool, version := range src.Tools {
+		if dst.Tools == nil {
+			dst.Tools = alphav1.Tools{}
+		}
+		dst.Tools[tool] = version
+	}
+}
+
+// cleanPath returns p relative to the repo root
+func cleanPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+func containsPath(paths []string, p string) bool {
+	for _, item := range paths {
+		if item == p {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,180 @@
+package alaska
+
+import (
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+)
+
+var _ = Describe("LoadConfig tests", func() {
+	var (
+		files map[string]string
+		reads []string
+		read  ReadFunc
+	)
+
+	BeforeEach(func() {
+		files = map[string]string{}
+		reads = nil
+		read = func(path string) ([]byte, error) {
+			reads = append(reads, path)
+			content, ok := files[path]
+			if !ok {
+				return nil, os.ErrNotExist
+			}
+			return []byte(content), nil
+		}
+	})
+
+	It("should load a config without includes", func() {
+		files["alaska.yaml"] = "strategy: sequential\nmanifests:\n- path: test.yaml\n"
+
+		cfg, err := LoadConfig(read, alphav1.DefaultConfigPath)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg).To(Equal(&alphav1.Config{
+			Strategy:  alphav1.StrategySequential,
+			Manifests: []*alphav1.ManifestOptions{{Path: "test.yaml"}},
+		}))
+	})
+
+	It("should merge includes relative to the including file before its own settings", func() {
+		files["services/api/alaska.yaml"] = `
+include:
+- common.yaml
+- /shared/tools.yaml
+namespace: api
+manifests:
+- path: services/api/deploy.yaml
+`
+		files["services/api/common.yaml"] = `
+namespace: default
+createNamespace: true
+manifests:
+- path: services/api/crds.yaml
+`
+		files["shared/tools.yaml"] = "tools:\n  kubectl: v1.15.x\n"
+
+		cfg, err := LoadConfig(read, "/services/api/alaska.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg).To(Equal(&alphav1.Config{
+			Namespace:       "api",
+			CreateNamespace: true,
+			Tools:           alphav1.Tools{alphav1.ToolKubectl: "v1.15.x"},
+			Manifests: []*alphav1.ManifestOptions{
+				{Path: "services/api/crds.yaml"},
+				{Path: "services/api/deploy.yaml"},
+			},
+		}))
+	})
+
+	It("should merge a file included twice once", func() {
+		files["alaska.yaml"] = "include: [a.yaml, b.yaml]\n"
+		files["a.yaml"] = "include: [common.yaml]\nmanifests:\n- path: a.yaml\n"
+		files["b.yaml"] = "include: [common.yaml]\nmanifests:\n- path: b.yaml\n"
+		files["common.yaml"] = "manifests:\n- path: common.yaml\n"
+
+		cfg, err := LoadConfig(read, "alaska.yaml")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(cfg.Manifests).To(Equal([]*alphav1.ManifestOptions{
+			{Path: "common.yaml"},
+			{Path: "a.yaml"},
+			{Path: "b.yaml"},
+		}))
+		Expect(reads).To(Equal([]string{"alaska.yaml", "a.yaml", "common.yaml", "b.yaml"}))
+	})
+
+	It("should reject include cycles", func() {
+		files["alaska.yaml"] = "include: [deploy/a.yaml]\n"
+		files["deploy/a.yaml"] = "include: [b.yaml]\n"
+		files["deploy/b.yaml"] = "include: [../alaska.yaml]\n"
+
+		_, err := LoadConfig(read, "alaska.yaml")
+		Expect(err).To(MatchError("deploy/b.yaml:1:1: include[0]: include cycle: alaska.yaml -> deploy/a.yaml -> deploy/b.yaml -> alaska.yaml"))
+	})
+
+	It("should return a FetchError for missing files", func() {
+		files["alaska.yaml"] = "include: [missing.yaml]\n"
+
+		_, err := LoadConfig(read, "alaska.yaml")
+		fetchErr, ok := err.(*FetchError)
+		Expect(ok).To(BeTrue())
+		Expect(fetchErr.Path).To(Equal("missing.yaml"))
+		Expect(fetchErr.Err).To(Equal(os.ErrNotExist))
+	})
+
+	It("should name the file that doesn't parse", func() {
+		files["alaska.yaml"] = "include: [bad.yaml]\n"
+		files["bad.yaml"] = "manifests: {"
+
+		_, err := LoadConfig(read, "alaska.yaml")
+		Expect(err).To(HaveOccurred())
+		Expect(err.Error()).To(HavePrefix("bad.yaml:1:1: "))
+		_, isParseErr := err.(*ParseError)
+		Expect(isParseErr).To(BeTrue())
+	})
+
+	It("should reject unknown fields with their line and column", func() {
+		files["alaska.yaml"] = `
+strategy: sequential
+manfests:
+- path: crds/
+manifests:
+- path: crds/
+  tpye: kubectl
+`
+
+		_, err := LoadConfig(read, "alaska.yaml")
+		Expect(err).To(MatchError(`alaska.yaml:3:1: unknown field "manfests"; alaska.yaml:7:3: unknown field "tpye"`))
+		_, isParseErr := err.(*ParseError)
+		Expect(isParseErr).To(BeTrue())
+	})
+
+	It("should reject duplicate fields", func() {
+		files["alaska.yaml"] = "manifests:\n- path: a.yaml\n  path: b.yaml\n"
+
+		_, err := LoadConfig(read, "alaska.yaml")
+		Expect(err).To(MatchError(`alaska.yaml:3:3: duplicate field "path"`))
+	})
+
+	It("should report invalid fields in the file they were read from", func() {
+		files["alaska.yaml"] = `
+include: [common.yaml]
+strategy: sideways
+manifests:
+- path: apps/
+- path: charts/nginx
+  type: helm
+`
+		files["common.yaml"] = `
+stages: []
+manifests:
+  - path: crds/
+  - type: helm
+    path: other/nginx
+    valuesFiles:
+      - values.yaml
+      - ../../secrets.yaml
+`
+
+		_, err := LoadConfig(read, "alaska.yaml")
+		validationErr, ok := err.(*ValidationError)
+		Expect(ok).To(BeTrue())
+		Expect(validationErr.Errors).To(Equal([]*ConfigError{
+			{
+				File: "alaska.yaml", Line: 3, Column: 1, Field: "strategy",
+				Message: `Unsupported value: "sideways": supported values: "parallel", "sequential"`,
+			},
+			{
+				File: "common.yaml", Line: 9, Column: 7, Field: "manifests[1].valuesFiles[1]",
+				Message: `Invalid value: "../../secrets.yaml": must be a path within the repo`,
+			},
+			{
+				File: "alaska.yaml", Line: 6, Column: 1, Field: "manifests[1].release",
+				Message: `Duplicate value: "nginx"`,
+			},
+		}))
+	})
+})
@@ -0,0 +1,22 @@
+package alaska
+
+import (
+	alphav1 "github.com/rudoi/alaska/api/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// GitCredentialsLabel marks the Secret and ServiceAccount holding a copy of a
+// Repo's git credentials, its value is the Repo's name
+const GitCredentialsLabel = "alpha.alaska.rudeboy.io/git-credentials"
+
+// GitCredentialsName is the name of the Secret and ServiceAccount that give a
+// Repo's pipeline access to its git remote
+func GitCredentialsName(repo *alphav1.Repo) string {
+	return repo.GetName() + "-git"
+}
+
+// IsGitCredentials reports whether obj is the Repo's copy of its git
+// credentials, rather than an object that happens to have the same name
+func IsGitCredentials(repo *alphav1.Repo, obj metav1.Object) bool {
+	return obj.GetName() == GitCredentialsName(repo) && obj.GetLabels()[GitCredentialsLabel] == repo.GetName()
+}
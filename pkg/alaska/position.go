@@ -0,0 +1,211 @@
+package alaska
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// yaml.v2 doesn't expose node positions, so fields are found in the source
+// text instead. This only understands block style YAML, flow style mappings
+// and sequences are reported at the key holding them.
+
+var (
+	keyRegexp     = regexp.MustCompile(`^(?:"([^"]*)"|'([^']*)'|([^\s"'#:][^#:]*?))\s*:(?:\s|$)`)
+	segmentRegexp = regexp.MustCompile(`[^.\[\]]+|\[[^\]]*\]`)
+)
+
+// segment is a mapping key or a sequence index of a field path
+type segment struct {
+	key   string
+	index int
+}
+
+// parseFieldPath splits a field path like manifests[0].tools[helm] into its
+// segments
+func parseFieldPath(fldPath string) []segment {
+	segments := []segment{}
+	for _, part := range segmentRegexp.FindAllString(fldPath, -1) {
+		if !strings.HasPrefix(part, "[") {
+			segments = append(segments, segment{key: part, index: -1})
+			continue
+		}
+
+		part = strings.Trim(part, "[]")
+		if i, err := strconv.Atoi(part); err == nil {
+			segments = append(segments, segment{index: i})
+		} else {
+			segments = append(segments, segment{key: part, index: -1})
+		}
+	}
+
+	return segments
+}
+
+// block is a range of lines holding a YAML node. Its content starts at col
+// on line start, and at each line's indentation after that.
+type block struct {
+	start, col, end int
+}
+
+type document struct {
+	lines []string
+}
+
+// position returns the 1-based line and column of fldPath in src, or of its
+// deepest parent that could be found. It returns 0, 0 if not even the first
+// segment is found.
+func position(src []byte, fldPath string) (int, int) {
+	doc := &document{lines: strings.Split(string(src), "\n")}
+	b := block{start: 0, col: -1, end: len(doc.lines)}
+
+	line, col := 0, 0
+	for _, seg := range parseFieldPath(fldPath) {
+		var (
+			found  bool
+			at, to int
+		)
+		if seg.index >= 0 {
+			at, to, b, found = doc.item(b, seg.index)
+		} else {
+			at, to, b, found = doc.key(b, seg.key)
+		}
+		if !found {
+			break
+		}
+
+		line, col = at+1, to+1
+	}
+
+	return line, col
+}
+
+// blank returns whether line i holds no YAML node
+func (d *document) blank(i int) bool {
+	text := strings.TrimSpace(d.lines[i])
+	return text == "" || strings.HasPrefix(text, "#") || text == "---"
+}
+
+func (d *document) indent(i int) int {
+	return len(d.lines[i]) - len(strings.TrimLeft(d.lines[i], " "))
+}
+
+// contentCol returns the column where b's content starts on line i
+func (d *document) contentCol(b block, i int) int {
+	if i == b.start && b.col >= 0 {
+		return b.col
+	}
+
+	return d.indent(i)
+}
+
+// first returns the first line of b holding a node, or -1
+func (d *document) first(b block) int {
+	for i := b.start; i < b.end; i++ {
+		if !d.blank(i) && d.contentCol(b, i) < len(d.lines[i]) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// key finds key in the mapping b, returning its line, column and the block
+// of its value
+func (d *document) key(b block, key string) (int, int, block, bool) {
+	first := d.first(b)
+	if first < 0 {
+		return 0, 0, block{}, false
+	}
+	col := d.contentCol(b, first)
+
+	for i := first; i < b.end; i++ {
+		if d.blank(i) || d.contentCol(b, i) != col {
+			continue
+		}
+
+		text := d.lines[i][col:]
+		match := keyRegexp.FindStringSubmatchIndex(text)
+		if match == nil {
+			continue
+		}
+		name := ""
+		for g := 1; g <= 3; g++ {
+			if match[2*g] >= 0 {
+				name = text[match[2*g]:match[2*g+1]]
+			}
+		}
+		if name != key {
+			continue
+		}
+
+		valueCol := col + match[1]
+		for valueCol < len(d.lines[i]) && d.lines[i][valueCol] == ' ' {
+			valueCol++
+		}
+		if valueCol < len(d.lines[i]) && d.lines[i][valueCol] != '#' {
+			// the value is on the key's line
+			return i, col, block{start: i, col: valueCol, end: i + 1}, true
+		}
+
+		// the value is every following line indented further, or a
+		// sequence at the key's indentation
+		end := i + 1
+		for ; end < b.end; end++ {
+			if d.blank(end) {
+				continue
+			}
+			indent := d.indent(end)
+			if indent < col || (indent == col && !strings.HasPrefix(d.lines[end][indent:], "-")) {
+				break
+			}
+		}
+
+		return i, col, block{start: i + 1, col: -1, end: end}, true
+	}
+
+	return 0, 0, block{}, false
+}
+
+// item finds the index-th item of the sequence b, returning its line, column
+// and block
+func (d *document) item(b block, index int) (int, int, block, bool) {
+	first := d.first(b)
+	if first < 0 {
+		return 0, 0, block{}, false
+	}
+	col := d.contentCol(b, first)
+
+	n := 0
+	for i := first; i < b.end; i++ {
+		if d.blank(i) || d.contentCol(b, i) != col {
+			continue
+		}
+
+		text := d.lines[i][col:]
+		if text != "-" && !strings.HasPrefix(text, "- ") {
+			continue
+		}
+
+		if n < index {
+			n++
+			continue
+		}
+
+		itemCol := col + 1
+		for itemCol < len(d.lines[i]) && d.lines[i][itemCol] == ' ' {
+			itemCol++
+		}
+
+		end := i + 1
+		for ; end < b.end; end++ {
+			if !d.blank(end) && d.indent(end) <= col {
+				break
+			}
+		}
+
+		return i, col, block{start: i, col: itemCol, end: end}, true
+	}
+
+	return 0, 0, block{}, false
+}
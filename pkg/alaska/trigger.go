@@ -6,24 +6,27 @@ import (
 
 	alphav1 "github.com/rudoi/alaska/api/v1"
 	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
 )
 
-func TriggerPipeline(ctx context.Context, c client.Client, repo *alphav1.Repo, config *alphav1.Config, sha string) error {
-	pipelineRun := &tektonv1.PipelineRun{
+// NewPipelineRun returns a PipelineRun deploying sha with the Repo's Pipeline
+func NewPipelineRun(repo *alphav1.Repo, sha string) *tektonv1.PipelineRun {
+	return &tektonv1.PipelineRun{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: tektonv1.SchemeGroupVersion.String(),
+			Kind:       "PipelineRun",
+		},
 		ObjectMeta: metav1.ObjectMeta{
 			GenerateName: fmt.Sprintf("%s-%s-", repo.GetName(), sha),
 			Namespace:    repo.GetNamespace(),
+			// the controller watches PipelineRuns through this reference
 			OwnerReferences: []metav1.OwnerReference{
-				{
-					APIVersion: alphav1.GroupVersion.Version,
-					Kind:       "Repo",
-					Name:       repo.GetName(),
-					UID:        repo.GetUID(),
-				},
+				*metav1.NewControllerRef(repo, alphav1.GroupVersion.WithKind("Repo")),
 			},
 		},
 		Spec: tektonv1.PipelineRunSpec{
@@ -46,6 +49,21 @@ func TriggerPipeline(ctx context.Context, c client.Client, repo *alphav1.Repo, c
 			},
 		},
 	}
+}
+
+func TriggerPipeline(ctx context.Context, c client.Client, repo *alphav1.Repo, config *alphav1.Config, sha string) error {
+	pipelineRun := NewPipelineRun(repo, sha)
+
+	// the controller keeps this ServiceAccount only while the Repo has git
+	// credentials, and deletes it when they're removed
+	sa := &corev1.ServiceAccount{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: repo.GetNamespace(), Name: GitCredentialsName(repo)}, sa); err == nil {
+		if IsGitCredentials(repo, sa) {
+			pipelineRun.Spec.ServiceAccount = sa.GetName()
+		}
+	} else if !apierrors.IsNotFound(err) {
+		return err
+	}
 
 	if err := c.Create(ctx, pipelineRun); err != nil {
 		return err
@@ -59,6 +77,7 @@ func TriggerPipeline(ctx context.Context, c client.Client, repo *alphav1.Repo, c
 			APIVersion: pipelineRun.APIVersion,
 			UID:        pipelineRun.GetUID(),
 		},
+		Commit: sha,
 	}
 
 	// put latest in front, limit to 5 total
@@ -0,0 +1,63 @@
+package alaska
+
+import (
+	"context"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	tektonv1 "github.com/tektoncd/pipeline/pkg/apis/pipeline/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+)
+
+var _ = Describe("TriggerPipeline tests", func() {
+	var repo *alphav1.Repo
+
+	BeforeEach(func() {
+		repo = &alphav1.Repo{
+			ObjectMeta: metav1.ObjectMeta{Name: "repo", Namespace: "default"},
+			Spec:       alphav1.RepoSpec{Cluster: "pizza"},
+		}
+	})
+
+	trigger := func(objs ...runtime.Object) *tektonv1.PipelineRun {
+		scheme := runtime.NewScheme()
+		Expect(corev1.AddToScheme(scheme)).To(Succeed())
+		Expect(tektonv1.AddToScheme(scheme)).To(Succeed())
+		c := fake.NewFakeClientWithScheme(scheme, objs...)
+
+		Expect(TriggerPipeline(context.Background(), c, repo, &alphav1.Config{}, "0123456789abcdef")).To(Succeed())
+
+		runs := &tektonv1.PipelineRunList{}
+		Expect(c.List(context.Background(), runs, client.InNamespace("default"))).To(Succeed())
+		Expect(runs.Items).To(HaveLen(1))
+		return &runs.Items[0]
+	}
+
+	It("should run as the Repo's git credentials ServiceAccount", func() {
+		sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{
+			Name:      "repo-git",
+			Namespace: "default",
+			Labels:    map[string]string{GitCredentialsLabel: "repo"},
+		}}
+
+		Expect(trigger(sa).Spec.ServiceAccount).To(Equal("repo-git"))
+		Expect(repo.Status.Runs).To(HaveLen(1))
+		Expect(repo.Status.Runs[0].Commit).To(Equal("0123456789abcdef"))
+	})
+
+	It("should ignore a ServiceAccount without the git credentials label", func() {
+		sa := &corev1.ServiceAccount{ObjectMeta: metav1.ObjectMeta{Name: "repo-git", Namespace: "default"}}
+
+		Expect(trigger(sa).Spec.ServiceAccount).To(BeEmpty())
+	})
+
+	It("should use the default ServiceAccount without git credentials", func() {
+		Expect(trigger().Spec.ServiceAccount).To(BeEmpty())
+	})
+})
@@ -0,0 +1,91 @@
+package git
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+)
+
+const (
+	// SecretTokenKey is the Secret key holding an access token
+	SecretTokenKey = "token"
+
+	// SecretKnownHostsKey is the Secret key holding known_hosts entries for SSH remotes
+	SecretKnownHostsKey = "known_hosts"
+)
+
+// Credentials authenticate to a git host. At most one of Token,
+// Username/Password and SSHPrivateKey is expected to be set.
+type Credentials struct {
+	Token         string
+	Username      string
+	Password      string
+	SSHPrivateKey []byte
+	KnownHosts    []byte
+}
+
+// CredentialsFromSecret reads Credentials from a Secret holding either a
+// token, basic auth (username/password) or an SSH key (ssh-privatekey). The
+// key names match the kubernetes.io/basic-auth and kubernetes.io/ssh-auth
+// Secret types.
+func CredentialsFromSecret(secret *corev1.Secret) (*Credentials, error) {
+	creds := &Credentials{
+		Token:         string(secret.Data[SecretTokenKey]),
+		Username:      string(secret.Data[corev1.BasicAuthUsernameKey]),
+		Password:      string(secret.Data[corev1.BasicAuthPasswordKey]),
+		SSHPrivateKey: secret.Data[corev1.SSHAuthPrivateKey],
+		KnownHosts:    secret.Data[SecretKnownHostsKey],
+	}
+
+	if creds.Token == "" && creds.Password == "" && len(creds.SSHPrivateKey) == 0 {
+		return nil, fmt.Errorf("secret %s/%s has none of %q, %q or %q", secret.GetNamespace(), secret.GetName(),
+			SecretTokenKey, corev1.BasicAuthPasswordKey, corev1.SSHAuthPrivateKey)
+	}
+
+	return creds, nil
+}
+
+// IsSSH returns true if the credentials hold an SSH key rather than an HTTP secret
+func (c *Credentials) IsSSH() bool {
+	return len(c.SSHPrivateKey) > 0
+}
+
+// Secret returns the token or password used for HTTP authentication
+func (c *Credentials) Secret() string {
+	if c.Token != "" {
+		return c.Token
+	}
+
+	return c.Password
+}
+
+// BasicAuth returns the username and password for HTTP basic auth. Tokens are
+// sent as the password with defaultUser as the username.
+func (c *Credentials) BasicAuth(defaultUser string) (string, string) {
+	if c.Username != "" {
+		return c.Username, c.Secret()
+	}
+
+	return defaultUser, c.Secret()
+}
+
+func (c *Credentials) authorizationHeader(defaultUser string) string {
+	if c.Secret() == "" {
+		return ""
+	}
+
+	user, pass := c.BasicAuth(defaultUser)
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+}
+
+// DefaultUsername is the username tokens are paired with for HTTP basic auth on provider
+func DefaultUsername(provider alphav1.GitProvider) string {
+	if provider == alphav1.ProviderGitLab {
+		return "oauth2"
+	}
+
+	return "x-access-token"
+}
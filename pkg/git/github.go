@@ -0,0 +1,155 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/google/go-github/v28/github"
+	"golang.org/x/oauth2"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+)
+
+// gitHubDotCom is the host whose API lives at api.github.com rather than on the host itself
+const gitHubDotCom = "github.com"
+
+// gitHubEndpoint is the API a GitHub Repo is read from. Empty URLs mean api.github.com.
+type gitHubEndpoint struct {
+	baseURL   string
+	uploadURL string
+}
+
+// pathPrefix returns the path a GitHub Enterprise Server is served below,
+// e.g. "ghe" for https://example.com/ghe/api/v3/
+func (e gitHubEndpoint) pathPrefix() string {
+	u, err := url.Parse(e.baseURL)
+	if err != nil {
+		return ""
+	}
+
+	return strings.Trim(strings.TrimSuffix(strings.Trim(u.Path, "/"), "api/v3"), "/")
+}
+
+// gitHubEndpoint picks the API for a Repo: the Repo's own override, then
+// api.github.com for github.com, then the Factory's enterprise URLs, and
+// finally the conventional /api/v3/ on the Repo's host
+func (f *Factory) gitHubEndpoint(repo *alphav1.Repo, repository *Repository) gitHubEndpoint {
+	if repo.Spec.GitHub != nil && repo.Spec.GitHub.BaseURL != "" {
+		return gitHubEndpoint{baseURL: repo.Spec.GitHub.BaseURL, uploadURL: repo.Spec.GitHub.UploadURL}
+	}
+
+	if strings.EqualFold(repository.Host, gitHubDotCom) {
+		return gitHubEndpoint{}
+	}
+
+	if f.GitHubBaseURL != "" {
+		return gitHubEndpoint{baseURL: f.GitHubBaseURL, uploadURL: f.GitHubUploadURL}
+	}
+
+	scheme := repository.Scheme
+	if scheme != "http" {
+		scheme = "https"
+	}
+
+	return gitHubEndpoint{
+		baseURL:   fmt.Sprintf("%s://%s/api/v3/", scheme, repository.Host),
+		uploadURL: fmt.Sprintf("%s://%s/api/uploads/", scheme, repository.Host),
+	}
+}
+
+// appServes returns true if the Factory's GitHubApp belongs to the GitHub instance at endpoint
+func (f *Factory) appServes(endpoint gitHubEndpoint) bool {
+	if f.GitHubApp == nil {
+		return false
+	}
+
+	return endpoint.baseURL == f.GitHubBaseURL
+}
+
+func (f *Factory) gitHubClient(endpoint gitHubEndpoint, creds *Credentials, repository *Repository) (*github.Client, error) {
+	var httpClient *http.Client
+	switch {
+	case creds != nil && creds.Token != "":
+		httpClient = oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: creds.Token}))
+	case creds != nil && creds.Password != "":
+		transport := &github.BasicAuthTransport{Username: creds.Username, Password: creds.Password}
+		httpClient = transport.Client()
+	case f.appServes(endpoint):
+		// SSH keys are only good for clones, so the API falls back to shared credentials
+		owner, _, err := repository.trimPrefix(endpoint.pathPrefix()).OwnerAndName()
+		if err != nil {
+			return nil, err
+		}
+		httpClient = oauth2.NewClient(context.Background(), f.GitHubApp.TokenSource(owner))
+	case f.GitHubToken != nil:
+		httpClient = oauth2.NewClient(context.Background(), f.GitHubToken)
+	}
+
+	if endpoint.baseURL == "" {
+		return github.NewClient(httpClient), nil
+	}
+
+	uploadURL := endpoint.uploadURL
+	if uploadURL == "" {
+		uploadURL = endpoint.baseURL
+	}
+
+	return github.NewEnterpriseClient(endpoint.baseURL, uploadURL, httpClient)
+}
+
+// GitHub is a Provider backed by the GitHub API
+type GitHub struct {
+	client *github.Client
+	owner  string
+	name   string
+}
+
+// NewGitHub returns a Provider for a repository on GitHub
+func NewGitHub(client *github.Client, repository *Repository) (*GitHub, error) {
+	owner, name, err := repository.OwnerAndName()
+	if err != nil {
+		return nil, err
+	}
+
+	return &GitHub{client: client, owner: owner, name: name}, nil
+}
+
+func (g *GitHub) Head(ctx context.Context, branch string) (string, error) {
+	b, _, err := g.client.Repositories.GetBranch(ctx, g.owner, g.name, branch)
+	if err != nil {
+		return "", err
+	}
+
+	return b.GetCommit().GetSHA(), nil
+}
+
+func (g *GitHub) DefaultBranch(ctx context.Context) (string, error) {
+	r, _, err := g.client.Repositories.Get(ctx, g.owner, g.name)
+	if err != nil {
+		return "", err
+	}
+
+	return r.GetDefaultBranch(), nil
+}
+
+func (g *GitHub) ReadFile(ctx context.Context, ref, path string) ([]byte, error) {
+	content, _, _, err := g.client.Repositories.GetContents(ctx, g.owner, g.name, path, &github.RepositoryContentGetOptions{Ref: ref})
+	if err != nil {
+		return nil, err
+	}
+
+	// GitHub lists a directory's entries instead of returning a file
+	if content == nil {
+		return nil, fmt.Errorf("%s is a directory", path)
+	}
+
+	decoded, err := content.GetContent()
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(decoded), nil
+}
@@ -0,0 +1,54 @@
+package git
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/google/go-github/v28/github"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GitHub provider tests", func() {
+	var (
+		server   *httptest.Server
+		provider *GitHub
+	)
+
+	BeforeEach(func() {
+		mux := http.NewServeMux()
+		mux.HandleFunc("/api/v3/repos/rudoi/alaska/contents/alaska.yaml", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`{"type":"file","encoding":"base64","content":"c3RyYXRlZ3k6IHNlcXVlbnRpYWwK"}`))
+		})
+		mux.HandleFunc("/api/v3/repos/rudoi/alaska/contents/charts", func(w http.ResponseWriter, r *http.Request) {
+			_, _ = w.Write([]byte(`[{"type":"file","name":"Chart.yaml","path":"charts/Chart.yaml"}]`))
+		})
+		server = httptest.NewServer(mux)
+
+		client, err := github.NewEnterpriseClient(server.URL+"/api/v3/", server.URL+"/api/uploads/", server.Client())
+		Expect(err).NotTo(HaveOccurred())
+
+		provider, err = NewGitHub(client, &Repository{Scheme: "http", Host: server.Listener.Addr().String(), Path: "rudoi/alaska"})
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("given a file", func() {
+		It("should return its decoded content", func() {
+			content, err := provider.ReadFile(context.Background(), "master", "alaska.yaml")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("strategy: sequential\n"))
+		})
+	})
+
+	Context("given a directory", func() {
+		It("should return an error instead of panicking", func() {
+			_, err := provider.ReadFile(context.Background(), "master", "charts")
+			Expect(err).To(MatchError("charts is a directory"))
+		})
+	})
+})
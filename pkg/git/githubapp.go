@@ -0,0 +1,246 @@
+package git
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v28/github"
+	"golang.org/x/oauth2"
+)
+
+// tokenRefreshWindow is how long before expiry a cached installation token is
+// replaced, so a clone started with it still has time to finish
+const tokenRefreshWindow = 10 * time.Minute
+
+// mintTimeout bounds minting a token, which isn't tied to any one caller
+const mintTimeout = 30 * time.Second
+
+// GitHubApp authenticates as a GitHub App and mints installation tokens for
+// the owners (users or organizations) it is installed on. Tokens are cached
+// per owner until they are about to expire.
+type GitHubApp struct {
+	id     int64
+	key    *rsa.PrivateKey
+	client *github.Client
+
+	// mu guards the maps below, it's never held across a request to GitHub
+	mu            sync.Mutex
+	installations map[string]int64
+	tokens        map[string]*oauth2.Token
+	minting       map[string]*tokenCall
+
+	// now is overridden in tests
+	now func() time.Time
+}
+
+// NewGitHubApp returns a GitHubApp for the app with the given ID and PEM
+// encoded private key. If baseURL is empty, api.github.com is used, otherwise
+// the GitHub Enterprise Server API at baseURL.
+func NewGitHubApp(id int64, privateKey []byte, baseURL string) (*GitHubApp, error) {
+	block, _ := pem.Decode(privateKey)
+	if block == nil {
+		return nil, errors.New("github app private key is not PEM encoded")
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse github app private key: %v", err)
+	}
+
+	app := &GitHubApp{
+		id:            id,
+		key:           key,
+		installations: map[string]int64{},
+		tokens:        map[string]*oauth2.Token{},
+		minting:       map[string]*tokenCall{},
+		now:           time.Now,
+	}
+
+	httpClient := &http.Client{Transport: &appTransport{app: app, base: http.DefaultTransport}}
+	if baseURL == "" {
+		app.client = github.NewClient(httpClient)
+		return app, nil
+	}
+
+	client, err := github.NewEnterpriseClient(baseURL, baseURL, httpClient)
+	if err != nil {
+		return nil, err
+	}
+	app.client = client
+
+	return app, nil
+}
+
+// tokenCall is a token being minted for an owner, which concurrent callers
+// for the same owner wait for instead of minting their own
+type tokenCall struct {
+	done  chan struct{}
+	token *oauth2.Token
+	err   error
+}
+
+// Token returns an installation token for owner
+func (a *GitHubApp) Token(ctx context.Context, owner string) (*oauth2.Token, error) {
+	a.mu.Lock()
+	if token, ok := a.tokens[owner]; ok && a.now().Add(tokenRefreshWindow).Before(token.Expiry) {
+		a.mu.Unlock()
+		return token, nil
+	}
+
+	call, ok := a.minting[owner]
+	if !ok {
+		call = &tokenCall{done: make(chan struct{})}
+		a.minting[owner] = call
+		go a.mintCall(owner, call)
+	}
+	a.mu.Unlock()
+
+	select {
+	case <-call.done:
+		return call.token, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// mintCall mints call's token with a context of its own, so a caller that
+// gives up doesn't fail the others waiting for the token
+func (a *GitHubApp) mintCall(owner string, call *tokenCall) {
+	ctx, cancel := context.WithTimeout(context.Background(), mintTimeout)
+	defer cancel()
+
+	call.token, call.err = a.mint(ctx, owner)
+
+	a.mu.Lock()
+	delete(a.minting, owner)
+	if call.err == nil {
+		a.tokens[owner] = call.token
+	}
+	a.mu.Unlock()
+	close(call.done)
+}
+
+// mint creates a new installation token for owner
+func (a *GitHubApp) mint(ctx context.Context, owner string) (*oauth2.Token, error) {
+	id, err := a.installation(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	minted, resp, err := a.client.Apps.CreateInstallationToken(ctx, id, nil)
+	if err != nil {
+		// the app was uninstalled, or reinstalled with a new ID
+		if resp != nil && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusNotFound) {
+			a.mu.Lock()
+			delete(a.installations, owner)
+			a.mu.Unlock()
+		}
+		return nil, fmt.Errorf("unable to create installation token for %s: %v", owner, err)
+	}
+
+	return &oauth2.Token{AccessToken: minted.GetToken(), Expiry: minted.GetExpiresAt()}, nil
+}
+
+// TokenSource returns an oauth2.TokenSource of installation tokens for owner
+func (a *GitHubApp) TokenSource(owner string) oauth2.TokenSource {
+	return &appTokenSource{app: a, owner: owner}
+}
+
+// installation looks up the installation ID for owner, which may be an
+// organization or a user
+func (a *GitHubApp) installation(ctx context.Context, owner string) (int64, error) {
+	a.mu.Lock()
+	id, ok := a.installations[owner]
+	a.mu.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	installation, resp, err := a.client.Apps.FindOrganizationInstallation(ctx, owner)
+	if resp != nil && resp.StatusCode == http.StatusNotFound {
+		installation, _, err = a.client.Apps.FindUserInstallation(ctx, owner)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("github app is not installed for %s: %v", owner, err)
+	}
+
+	a.mu.Lock()
+	a.installations[owner] = installation.GetID()
+	a.mu.Unlock()
+	return installation.GetID(), nil
+}
+
+// jwt returns a short-lived JSON Web Token identifying the app itself
+func (a *GitHubApp) jwt() (string, error) {
+	now := a.now()
+
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]int64{
+		// backdated to allow for clock drift, GitHub caps exp at 10 minutes
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": a.id,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// appTransport authenticates requests as the app rather than an installation
+type appTransport struct {
+	app  *GitHubApp
+	base http.RoundTripper
+}
+
+func (t *appTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.app.jwt()
+	if err != nil {
+		return nil, err
+	}
+
+	// RoundTrippers must not modify the request they are given
+	authed := new(http.Request)
+	*authed = *req
+	authed.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		authed.Header[k] = v
+	}
+	authed.Header.Set("Authorization", "Bearer "+token)
+
+	return t.base.RoundTrip(authed)
+}
+
+type appTokenSource struct {
+	app   *GitHubApp
+	owner string
+}
+
+func (s *appTokenSource) Token() (*oauth2.Token, error) {
+	return s.app.Token(context.Background(), s.owner)
+}
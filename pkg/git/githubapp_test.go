@@ -0,0 +1,225 @@
+package git
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GitHubApp tests", func() {
+	var (
+		key    *rsa.PrivateKey
+		server *httptest.Server
+		app    *GitHubApp
+		now    time.Time
+		minted int
+
+		// lookups counts installation lookups, revoked makes minting fail
+		// with a 404 and release, if set, holds minting requests until closed
+		lookups int
+		revoked bool
+		release chan struct{}
+	)
+
+	// verifyJWT checks the bearer token is an RS256 JWT for app 1234 signed by key
+	verifyJWT := func(r *http.Request) bool {
+		parts := strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "), ".")
+		if len(parts) != 3 {
+			return false
+		}
+
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		if err != nil {
+			return false
+		}
+
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature) != nil {
+			return false
+		}
+
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		claims := map[string]int64{}
+		return json.Unmarshal(payload, &claims) == nil && claims["iss"] == 1234
+	}
+
+	BeforeEach(func() {
+		var err error
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+		Expect(err).NotTo(HaveOccurred())
+
+		minted = 0
+		lookups = 0
+		revoked = false
+		release = nil
+		now = time.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/orgs/rudoi/installation", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+		mux.HandleFunc("/users/rudoi/installation", func(w http.ResponseWriter, r *http.Request) {
+			if !verifyJWT(r) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			lookups++
+			_, _ = w.Write([]byte(`{"id": 42}`))
+		})
+		mux.HandleFunc("/app/installations/42/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost || !verifyJWT(r) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			if revoked {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			if release != nil {
+				<-release
+			}
+			minted++
+			w.WriteHeader(http.StatusCreated)
+			_, _ = fmt.Fprintf(w, `{"token": "v1.token-%d", "expires_at": %q}`, minted, now.Add(time.Hour).Format(time.RFC3339))
+		})
+		server = httptest.NewServer(mux)
+
+		pemKey := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+		app, err = NewGitHubApp(1234, pemKey, server.URL+"/")
+		Expect(err).NotTo(HaveOccurred())
+		app.now = func() time.Time { return now }
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("given an owner the app is installed on", func() {
+		It("should mint an installation token", func() {
+			token, err := app.Token(context.Background(), "rudoi")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token.AccessToken).To(Equal("v1.token-1"))
+		})
+
+		It("should reuse the token until it is about to expire", func() {
+			_, err := app.Token(context.Background(), "rudoi")
+			Expect(err).NotTo(HaveOccurred())
+
+			now = now.Add(30 * time.Minute)
+			token, err := app.Token(context.Background(), "rudoi")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token.AccessToken).To(Equal("v1.token-1"))
+
+			now = now.Add(25 * time.Minute)
+			token, err = app.Token(context.Background(), "rudoi")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token.AccessToken).To(Equal("v1.token-2"))
+			Expect(minted).To(Equal(2))
+		})
+	})
+
+	Context("given concurrent requests for the same owner", func() {
+		It("should mint one token without blocking other owners", func() {
+			release = make(chan struct{})
+
+			tokens := make(chan string, 3)
+			for i := 0; i < 3; i++ {
+				go func() {
+					defer GinkgoRecover()
+					token, err := app.Token(context.Background(), "rudoi")
+					Expect(err).NotTo(HaveOccurred())
+					tokens <- token.AccessToken
+				}()
+			}
+
+			// the first request is stuck minting, other owners still get an answer
+			Eventually(func() bool {
+				app.mu.Lock()
+				defer app.mu.Unlock()
+				return app.minting["rudoi"] != nil
+			}).Should(BeTrue())
+			_, err := app.Token(context.Background(), "someone-else")
+			Expect(err).To(HaveOccurred())
+
+			close(release)
+			for i := 0; i < 3; i++ {
+				Eventually(tokens).Should(Receive(Equal("v1.token-1")))
+			}
+			Expect(minted).To(Equal(1))
+		})
+	})
+
+	Context("given a first request that gives up while minting", func() {
+		It("should still hand the token to the requests waiting for it", func() {
+			release = make(chan struct{})
+
+			ctx, cancel := context.WithCancel(context.Background())
+			first := make(chan error, 1)
+			go func() {
+				_, err := app.Token(ctx, "rudoi")
+				first <- err
+			}()
+
+			Eventually(func() bool {
+				app.mu.Lock()
+				defer app.mu.Unlock()
+				return app.minting["rudoi"] != nil
+			}).Should(BeTrue())
+
+			tokens := make(chan string, 1)
+			go func() {
+				defer GinkgoRecover()
+				token, err := app.Token(context.Background(), "rudoi")
+				Expect(err).NotTo(HaveOccurred())
+				tokens <- token.AccessToken
+			}()
+
+			cancel()
+			Eventually(first).Should(Receive(Equal(context.Canceled)))
+
+			close(release)
+			Eventually(tokens).Should(Receive(Equal("v1.token-1")))
+			Expect(minted).To(Equal(1))
+		})
+	})
+
+	Context("given an installation that was removed", func() {
+		It("should look the installation up again", func() {
+			_, err := app.Token(context.Background(), "rudoi")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lookups).To(Equal(1))
+
+			revoked = true
+			now = now.Add(time.Hour)
+			_, err = app.Token(context.Background(), "rudoi")
+			Expect(err).To(HaveOccurred())
+
+			revoked = false
+			token, err := app.Token(context.Background(), "rudoi")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(token.AccessToken).To(Equal("v1.token-2"))
+			Expect(lookups).To(Equal(2))
+		})
+	})
+
+	Context("given an owner the app is not installed on", func() {
+		It("should return an error", func() {
+			_, err := app.Token(context.Background(), "someone-else")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
@@ -0,0 +1,113 @@
+package git
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+)
+
+// GitLab is a Provider backed by the GitLab v4 REST API
+type GitLab struct {
+	client  *http.Client
+	baseURL string
+	project string
+	token   string
+}
+
+// NewGitLab returns a Provider for a project on a GitLab host. The API is
+// expected at /api/v4 on the same host as the repository.
+func NewGitLab(client *http.Client, repository *Repository, token string) *GitLab {
+	scheme := repository.Scheme
+	if scheme == "" || scheme == "ssh" {
+		scheme = "https"
+	}
+
+	return &GitLab{
+		client:  client,
+		baseURL: fmt.Sprintf("%s://%s/api/v4", scheme, repository.Host),
+		project: repository.Path,
+		token:   token,
+	}
+}
+
+type gitlabBranch struct {
+	Commit struct {
+		ID string `json:"id"`
+	} `json:"commit"`
+}
+
+func (g *GitLab) Head(ctx context.Context, branch string) (string, error) {
+	body, err := g.get(ctx, fmt.Sprintf("/projects/%s/repository/branches/%s", url.PathEscape(g.project), url.PathEscape(branch)))
+	if err != nil {
+		return "", err
+	}
+
+	b := &gitlabBranch{}
+	if err := json.Unmarshal(body, b); err != nil {
+		return "", err
+	}
+
+	if b.Commit.ID == "" {
+		return "", fmt.Errorf("gitlab: branch %q has no commit", branch)
+	}
+
+	return b.Commit.ID, nil
+}
+
+func (g *GitLab) ReadFile(ctx context.Context, ref, path string) ([]byte, error) {
+	return g.get(ctx, fmt.Sprintf("/projects/%s/repository/files/%s/raw?ref=%s", url.PathEscape(g.project), url.PathEscape(path), url.QueryEscape(ref)))
+}
+
+type gitlabProject struct {
+	DefaultBranch string `json:"default_branch"`
+}
+
+func (g *GitLab) DefaultBranch(ctx context.Context) (string, error) {
+	body, err := g.get(ctx, fmt.Sprintf("/projects/%s", url.PathEscape(g.project)))
+	if err != nil {
+		return "", err
+	}
+
+	p := &gitlabProject{}
+	if err := json.Unmarshal(body, p); err != nil {
+		return "", err
+	}
+
+	if p.DefaultBranch == "" {
+		return "", fmt.Errorf("gitlab: project %q has no default branch", g.project)
+	}
+
+	return p.DefaultBranch, nil
+}
+
+func (g *GitLab) get(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, g.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+
+	if g.token != "" {
+		req.Header.Set("PRIVATE-TOKEN", g.token)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gitlab: GET %s: %s", req.URL.Path, resp.Status)
+	}
+
+	return body, nil
+}
@@ -0,0 +1,98 @@
+package git
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GitLab provider tests", func() {
+	var (
+		server   *httptest.Server
+		provider *GitLab
+		requests []*http.Request
+	)
+
+	BeforeEach(func() {
+		requests = nil
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/api/v4/projects/", func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r)
+
+			if r.Header.Get("PRIVATE-TOKEN") != "glpat-test" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+
+			switch r.URL.EscapedPath() {
+			case "/api/v4/projects/group%2Fsubgroup%2Fmanifests":
+				_, _ = w.Write([]byte(`{"id":42,"default_branch":"main"}`))
+			case "/api/v4/projects/group%2Fsubgroup%2Fmanifests/repository/branches/master":
+				_, _ = w.Write([]byte(`{"name":"master","commit":{"id":"6104942438c14ec7bd21c6cd5bd995272b3faff6"}}`))
+			case "/api/v4/projects/group%2Fsubgroup%2Fmanifests/repository/files/alaska.yaml/raw":
+				if r.URL.Query().Get("ref") != "6104942438c14ec7bd21c6cd5bd995272b3faff6" {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+				_, _ = w.Write([]byte("strategy: sequential\n"))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		})
+		server = httptest.NewServer(mux)
+
+		u, err := url.Parse(server.URL)
+		Expect(err).NotTo(HaveOccurred())
+
+		provider = NewGitLab(server.Client(), &Repository{Scheme: "http", Host: u.Host, Path: "group/subgroup/manifests"}, "glpat-test")
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("given an existing branch", func() {
+		It("should return the branch head", func() {
+			head, err := provider.Head(context.Background(), "master")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(head).To(Equal("6104942438c14ec7bd21c6cd5bd995272b3faff6"))
+		})
+	})
+
+	Context("given a missing branch", func() {
+		It("should return an error", func() {
+			_, err := provider.Head(context.Background(), "nope")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("given a project", func() {
+		It("should return its default branch", func() {
+			branch, err := provider.DefaultBranch(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(branch).To(Equal("main"))
+		})
+	})
+
+	Context("given a file at a ref", func() {
+		It("should return the raw file contents", func() {
+			content, err := provider.ReadFile(context.Background(), "6104942438c14ec7bd21c6cd5bd995272b3faff6", "alaska.yaml")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("strategy: sequential\n"))
+		})
+	})
+
+	Context("given a bad token", func() {
+		It("should return an error", func() {
+			provider.token = "wrong"
+			_, err := provider.Head(context.Background(), "master")
+			Expect(err).To(HaveOccurred())
+			Expect(requests).To(HaveLen(1))
+		})
+	})
+})
@@ -0,0 +1,240 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+)
+
+// Plain is a Provider that speaks the git protocol directly, for hosts
+// Alaska has no API client for. It shells out to the git binary and speaks
+// https, http, ssh or the git protocol.
+type Plain struct {
+	url   string
+	creds *Credentials
+}
+
+// plainSchemes are the URL schemes Plain hands to git. Others, like ext::,
+// run arbitrary commands, and file:// would read the controller's own disk.
+var plainSchemes = map[string]bool{"https": true, "http": true, "ssh": true, "git": true}
+
+// allowProtocols keeps git itself to plainSchemes, e.g. when following
+// redirects
+const allowProtocols = "GIT_ALLOW_PROTOCOL=https:http:ssh:git"
+
+// scpRegexp matches scp-like SSH remotes such as git@example.com:team/repo.git
+var scpRegexp = regexp.MustCompile(`^(?:[A-Za-z0-9._~][A-Za-z0-9._~-]*@)?[A-Za-z0-9][A-Za-z0-9.-]*:[^-/\s][^\s]*$`)
+
+// NewPlain returns a Provider for the repository at url, authenticating
+// with creds if they are not nil
+func NewPlain(url string, creds *Credentials) *Plain {
+	return &Plain{url: url, creds: creds}
+}
+
+// ValidatePlainURL checks that raw is an https, http, ssh or git URL or an
+// scp-like SSH remote. Anything git could read as an option is rejected.
+func ValidatePlainURL(raw string) error {
+	if raw == "" || strings.HasPrefix(raw, "-") {
+		return fmt.Errorf("invalid git url %q", raw)
+	}
+
+	if !strings.Contains(raw, "://") {
+		if !scpRegexp.MatchString(raw) {
+			return fmt.Errorf("invalid git url %q, expected a URL or user@host:path", raw)
+		}
+		return nil
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid git url %q: %v", raw, err)
+	}
+
+	if !plainSchemes[u.Scheme] {
+		return fmt.Errorf("invalid git url %q, scheme must be one of https, http, ssh or git", raw)
+	}
+
+	// ssh reads a host or user starting with - as an option
+	if u.Host == "" || strings.HasPrefix(u.Host, "-") || strings.HasPrefix(u.User.Username(), "-") {
+		return fmt.Errorf("invalid git url %q", raw)
+	}
+
+	return nil
+}
+
+// validateRef rejects refs git could read as an option
+func validateRef(ref string) error {
+	if ref == "" || strings.HasPrefix(ref, "-") || strings.ContainsAny(ref, " \t\n") {
+		return fmt.Errorf("git: invalid ref %q", ref)
+	}
+
+	return nil
+}
+
+// Head looks up branch with ls-remote, without cloning the repository
+func (p *Plain) Head(ctx context.Context, branch string) (string, error) {
+	if err := validateRef(branch); err != nil {
+		return "", err
+	}
+
+	ref := "refs/heads/" + branch
+	out, err := p.git(ctx, "", "ls-remote", "--", p.url, ref)
+	if err != nil {
+		return "", err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[1] == ref {
+			return fields[0], nil
+		}
+	}
+
+	return "", fmt.Errorf("git: branch %q not found in %s", branch, p.url)
+}
+
+func (p *Plain) DefaultBranch(ctx context.Context) (string, error) {
+	out, err := p.git(ctx, "", "ls-remote", "--symref", "--", p.url, "HEAD")
+	if err != nil {
+		return "", err
+	}
+
+	// ref: refs/heads/master	HEAD
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "ref:" && fields[2] == "HEAD" {
+			return strings.TrimPrefix(fields[1], "refs/heads/"), nil
+		}
+	}
+
+	return "", fmt.Errorf("git: %s has no HEAD", p.url)
+}
+
+// ReadFile fetches only the commit at ref into a scratch repository and
+// reads path from it. Where the server supports partial clone, blobs other
+// than path are never downloaded. Servers only hand out commits by full SHA,
+// so for an abbreviated SHA the history of every branch is fetched instead.
+func (p *Plain) ReadFile(ctx context.Context, ref, path string) ([]byte, error) {
+	if err := validateRef(ref); err != nil {
+		return nil, err
+	}
+
+	dir, err := ioutil.TempDir("", "alaska-git-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	fetch := []string{"fetch", "--quiet", "--depth", "1", "--filter=blob:none", "--", "origin", ref}
+	if abbreviatedSHA(ref) {
+		fetch = []string{"fetch", "--quiet", "--filter=blob:none", "--", "origin", "+refs/heads/*:refs/remotes/origin/*"}
+	}
+
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"remote", "add", "--", "origin", p.url},
+		fetch,
+	} {
+		if _, err := p.git(ctx, dir, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	return p.git(ctx, dir, "show", fmt.Sprintf("%s:%s", ref, path), "--")
+}
+
+// abbreviatedSHA returns whether ref looks like a commit SHA shorter than 40
+// characters
+func abbreviatedSHA(ref string) bool {
+	if len(ref) < 4 || len(ref) >= 40 {
+		return false
+	}
+
+	for _, c := range ref {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *Plain) git(ctx context.Context, dir string, args ...string) ([]byte, error) {
+	if err := ValidatePlainURL(p.url); err != nil {
+		return nil, err
+	}
+
+	env, cleanup, err := p.authEnv()
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(append(os.Environ(), "GIT_TERMINAL_PROMPT=0", allowProtocols), env...)
+
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %v: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+
+	return out, nil
+}
+
+// authEnv returns the environment that makes git authenticate with p's
+// credentials. Config goes through the environment rather than -c so secrets
+// don't show up in the process list.
+func (p *Plain) authEnv() ([]string, func(), error) {
+	noop := func() {}
+	if p.creds == nil {
+		return nil, noop, nil
+	}
+
+	if !p.creds.IsSSH() {
+		header := p.creds.authorizationHeader(DefaultUsername(alphav1.ProviderGit))
+		// same format git uses to pass -c options on to subprocesses
+		return []string{"GIT_CONFIG_PARAMETERS='http.extraHeader=Authorization: " + header + "'"}, noop, nil
+	}
+
+	dir, err := ioutil.TempDir("", "alaska-ssh-")
+	if err != nil {
+		return nil, noop, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+
+	key := filepath.Join(dir, "id")
+	if err := ioutil.WriteFile(key, p.creds.SSHPrivateKey, 0600); err != nil {
+		cleanup()
+		return nil, noop, err
+	}
+
+	sshCommand := fmt.Sprintf("ssh -i %s -o IdentitiesOnly=yes", key)
+	if len(p.creds.KnownHosts) > 0 {
+		knownHosts := filepath.Join(dir, "known_hosts")
+		if err := ioutil.WriteFile(knownHosts, p.creds.KnownHosts, 0600); err != nil {
+			cleanup()
+			return nil, noop, err
+		}
+		sshCommand += fmt.Sprintf(" -o UserKnownHostsFile=%s", knownHosts)
+	}
+	// without known_hosts in the Secret, only hosts the image already trusts are accepted
+	sshCommand += " -o StrictHostKeyChecking=yes"
+
+	return []string{"GIT_SSH_COMMAND=" + sshCommand}, cleanup, nil
+}
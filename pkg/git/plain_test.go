@@ -0,0 +1,129 @@
+package git
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Plain git provider tests", func() {
+	var (
+		dir      string
+		head     string
+		server   *httptest.Server
+		provider *Plain
+	)
+
+	run := func(dir string, args ...string) string {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=alaska", "GIT_AUTHOR_EMAIL=alaska@example.com",
+			"GIT_COMMITTER_NAME=alaska", "GIT_COMMITTER_EMAIL=alaska@example.com",
+		)
+		out, err := cmd.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(out))
+		return strings.TrimSpace(string(out))
+	}
+
+	BeforeEach(func() {
+		if _, err := exec.LookPath("git"); err != nil {
+			Skip("git binary not available")
+		}
+
+		var err error
+		dir, err = ioutil.TempDir("", "alaska-plain-test-")
+		Expect(err).NotTo(HaveOccurred())
+
+		remote := filepath.Join(dir, "remote.git")
+		work := filepath.Join(dir, "work")
+		run(dir, "init", "--quiet", "--bare", remote)
+		run(dir, "init", "--quiet", work)
+
+		Expect(ioutil.WriteFile(filepath.Join(work, "alaska.yaml"), []byte("strategy: sequential\n"), 0644)).To(Succeed())
+		run(work, "add", "alaska.yaml")
+		run(work, "commit", "--quiet", "-m", "add config")
+		run(work, "push", "--quiet", remote, "HEAD:refs/heads/master")
+		head = run(work, "rev-parse", "HEAD")
+
+		// serve the remote the way git hosts do, through git http-backend
+		backend := &cgi.Handler{
+			Path: filepath.Join(run(dir, "--exec-path"), "git-http-backend"),
+			Env:  []string{"GIT_PROJECT_ROOT=" + dir, "GIT_HTTP_EXPORT_ALL=1"},
+		}
+		server = httptest.NewServer(backend)
+
+		provider = NewPlain(server.URL+"/remote.git", nil)
+	})
+
+	AfterEach(func() {
+		server.Close()
+		os.RemoveAll(dir)
+	})
+
+	Context("given an existing branch", func() {
+		It("should return the branch head from ls-remote", func() {
+			sha, err := provider.Head(context.Background(), "master")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(sha).To(Equal(head))
+		})
+	})
+
+	Context("given a missing branch", func() {
+		It("should return an error", func() {
+			_, err := provider.Head(context.Background(), "nope")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("given a remote HEAD", func() {
+		It("should return the branch it points at", func() {
+			run(filepath.Join(dir, "remote.git"), "symbolic-ref", "HEAD", "refs/heads/master")
+
+			branch, err := provider.DefaultBranch(context.Background())
+			Expect(err).NotTo(HaveOccurred())
+			Expect(branch).To(Equal("master"))
+		})
+	})
+
+	Context("given a file at the branch head", func() {
+		It("should return its contents from a shallow fetch", func() {
+			content, err := provider.ReadFile(context.Background(), head, "alaska.yaml")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("strategy: sequential\n"))
+		})
+	})
+
+	Context("given an abbreviated commit SHA", func() {
+		It("should find the commit in the fetched branches", func() {
+			content, err := provider.ReadFile(context.Background(), head[:7], "alaska.yaml")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(content)).To(Equal("strategy: sequential\n"))
+		})
+	})
+
+	Context("given refs that look like options", func() {
+		It("should reject them without running git", func() {
+			_, err := provider.Head(context.Background(), "--upload-pack=touch")
+			Expect(err).To(MatchError(ContainSubstring("invalid ref")))
+
+			_, err = provider.ReadFile(context.Background(), "--upload-pack=touch", "alaska.yaml")
+			Expect(err).To(MatchError(ContainSubstring("invalid ref")))
+		})
+	})
+
+	Context("given a missing file", func() {
+		It("should return an error", func() {
+			_, err := provider.ReadFile(context.Background(), head, "nope.yaml")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
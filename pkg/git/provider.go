@@ -0,0 +1,214 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+)
+
+// Provider looks up branch heads and reads files from a hosted git repository
+type Provider interface {
+	// Head returns the full commit SHA at the tip of branch
+	Head(ctx context.Context, branch string) (string, error)
+
+	// ReadFile returns the contents of the file at path as of ref
+	ReadFile(ctx context.Context, ref, path string) ([]byte, error)
+
+	// DefaultBranch returns the name of the branch the repository's HEAD points at
+	DefaultBranch(ctx context.Context) (string, error)
+}
+
+// Repository identifies a repository on a git host
+type Repository struct {
+	Scheme string
+	Host   string
+
+	// Path is the full repository path without a .git suffix, e.g. "rudoi/alaska"
+	// or "group/subgroup/project"
+	Path string
+}
+
+// ParseURL parses a Repo's spec.url into a Repository. Besides URLs with a
+// scheme, scp-like SSH remotes such as git@github.com:rudoi/alaska.git are
+// accepted.
+func ParseURL(raw string) (*Repository, error) {
+	if !strings.Contains(raw, "://") {
+		if i := strings.Index(raw, ":"); i > 0 {
+			raw = "ssh://" + raw[:i] + "/" + raw[i+1:]
+		}
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	path := strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git")
+	if u.Host == "" || !strings.Contains(path, "/") {
+		return nil, fmt.Errorf("unable to parse repository from url %q", raw)
+	}
+
+	return &Repository{Scheme: u.Scheme, Host: u.Host, Path: path}, nil
+}
+
+// trimPrefix returns a copy of r with a leading path prefix removed, for
+// GitHub Enterprise Servers served below a path
+func (r *Repository) trimPrefix(prefix string) *Repository {
+	trimmed := *r
+	if prefix != "" {
+		trimmed.Path = strings.TrimPrefix(r.Path, prefix+"/")
+	}
+
+	return &trimmed
+}
+
+// OwnerAndName splits a repository path into the owner and repository name
+// GitHub expects
+func (r *Repository) OwnerAndName() (string, string, error) {
+	parts := strings.Split(r.Path, "/")
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("expected owner/name repository path, got %q", r.Path)
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// Factory builds the Provider for a Repo
+type Factory struct {
+	// GitHubToken authenticates Repos on GitHub without their own credentials
+	GitHubToken oauth2.TokenSource
+
+	// GitHubApp, if set, replaces GitHubToken with installation tokens minted
+	// for the owner of each Repo on the GitHub instance at GitHubBaseURL
+	GitHubApp *GitHubApp
+
+	// GitHubBaseURL and GitHubUploadURL point Repos on hosts other than
+	// github.com at a GitHub Enterprise Server API. If unset, the API is
+	// expected at /api/v3/ on the Repo's host.
+	GitHubBaseURL   string
+	GitHubUploadURL string
+
+	// GitLabToken is the access token used for Repos on GitLab without their own credentials
+	GitLabToken string
+
+	// HTTPClient is used for providers without a dedicated client, defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// ForRepo returns the Provider for a Repo, chosen from spec.provider or,
+// if unset, from the host of spec.url. Plain git is never inferred. If creds
+// is nil, the Factory's shared credentials are used.
+func (f *Factory) ForRepo(repo *alphav1.Repo, creds *Credentials) (Provider, error) {
+	if repo.Spec.Provider == alphav1.ProviderGit {
+		if err := ValidatePlainURL(repo.Spec.URL); err != nil {
+			return nil, err
+		}
+		return NewPlain(repo.Spec.URL, creds), nil
+	}
+
+	repository, err := ParseURL(repo.Spec.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch ProviderFor(repo, repository) {
+	case alphav1.ProviderGitHub:
+		endpoint := f.gitHubEndpoint(repo, repository)
+		client, err := f.gitHubClient(endpoint, creds, repository)
+		if err != nil {
+			return nil, err
+		}
+		return NewGitHub(client, repository.trimPrefix(endpoint.pathPrefix()))
+	case alphav1.ProviderGitLab:
+		token := f.GitLabToken
+		if creds != nil && creds.Secret() != "" {
+			token = creds.Secret()
+		}
+		return NewGitLab(f.httpClient(), repository, token), nil
+	default:
+		return nil, fmt.Errorf("unknown git provider %q", repo.Spec.Provider)
+	}
+}
+
+// Validate checks that spec.url is a URL the Repo's provider understands
+func (f *Factory) Validate(repo *alphav1.Repo) error {
+	if repo.Spec.Provider == alphav1.ProviderGit {
+		return ValidatePlainURL(repo.Spec.URL)
+	}
+
+	repository, err := ParseURL(repo.Spec.URL)
+	if err != nil {
+		return err
+	}
+
+	if ProviderFor(repo, repository) == alphav1.ProviderGitHub {
+		endpoint := f.gitHubEndpoint(repo, repository)
+		if _, _, err := repository.trimPrefix(endpoint.pathPrefix()).OwnerAndName(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ProviderFor returns the provider a Repo is served by
+func ProviderFor(repo *alphav1.Repo, repository *Repository) alphav1.GitProvider {
+	if repo.Spec.Provider != "" {
+		return repo.Spec.Provider
+	}
+
+	if strings.Contains(strings.ToLower(repository.Host), "gitlab") {
+		return alphav1.ProviderGitLab
+	}
+
+	return alphav1.ProviderGitHub
+}
+
+// AppCredentials returns a GitHub App installation token for a Repo on
+// GitHub, or nil if the Factory has no GitHubApp or the Repo isn't on the
+// GitHub instance the app belongs to
+func (f *Factory) AppCredentials(ctx context.Context, repo *alphav1.Repo) (*Credentials, error) {
+	if f.GitHubApp == nil || repo.Spec.Provider == alphav1.ProviderGit {
+		return nil, nil
+	}
+
+	repository, err := ParseURL(repo.Spec.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if ProviderFor(repo, repository) != alphav1.ProviderGitHub {
+		return nil, nil
+	}
+
+	endpoint := f.gitHubEndpoint(repo, repository)
+	if !f.appServes(endpoint) {
+		return nil, nil
+	}
+
+	owner, _, err := repository.trimPrefix(endpoint.pathPrefix()).OwnerAndName()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := f.GitHubApp.Token(ctx, owner)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Credentials{Token: token.AccessToken}, nil
+}
+
+func (f *Factory) httpClient() *http.Client {
+	if f.HTTPClient == nil {
+		return http.DefaultClient
+	}
+
+	return f.HTTPClient
+}
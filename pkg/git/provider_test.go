@@ -0,0 +1,112 @@
+package git
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+)
+
+var _ = Describe("Factory tests", func() {
+	var (
+		factory *Factory
+		repo    *alphav1.Repo
+	)
+
+	BeforeEach(func() {
+		factory = &Factory{}
+		repo = &alphav1.Repo{Spec: alphav1.RepoSpec{Branch: "master", Cluster: "pizza"}}
+	})
+
+	Context("given a github.com URL", func() {
+		It("should return a GitHub provider", func() {
+			repo.Spec.URL = "https://github.com/rudoi/alaska-test.git"
+			provider, err := factory.ForRepo(repo, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(provider).To(BeAssignableToTypeOf(&GitHub{}))
+		})
+	})
+
+	Context("given a GitLab URL with nested groups", func() {
+		It("should return a GitLab provider", func() {
+			repo.Spec.URL = "https://gitlab.example.com/group/subgroup/manifests.git"
+			provider, err := factory.ForRepo(repo, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(provider).To(BeAssignableToTypeOf(&GitLab{}))
+			Expect(provider.(*GitLab).project).To(Equal("group/subgroup/manifests"))
+		})
+	})
+
+	Context("given an explicit provider", func() {
+		It("should prefer it over the URL host", func() {
+			repo.Spec.URL = "https://git.example.com/team/manifests.git"
+			repo.Spec.Provider = alphav1.ProviderGitLab
+			provider, err := factory.ForRepo(repo, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(provider).To(BeAssignableToTypeOf(&GitLab{}))
+		})
+	})
+
+	Context("given a GitHub Enterprise URL and no configured base URL", func() {
+		It("should use the API on the Repo's host", func() {
+			repo.Spec.URL = "https://github.example.com/platform/manifests.git"
+			provider, err := factory.ForRepo(repo, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(provider.(*GitHub).client.BaseURL.String()).To(Equal("https://github.example.com/api/v3/"))
+		})
+	})
+
+	Context("given a GitHub Enterprise Server served below a path", func() {
+		It("should strip the path from the repository", func() {
+			repo.Spec.URL = "https://example.com/ghe/platform/manifests.git"
+			repo.Spec.GitHub = &alphav1.GitHubSpec{BaseURL: "https://example.com/ghe/api/v3/"}
+			provider, err := factory.ForRepo(repo, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(provider.(*GitHub).owner).To(Equal("platform"))
+			Expect(provider.(*GitHub).name).To(Equal("manifests"))
+			Expect(provider.(*GitHub).client.BaseURL.String()).To(Equal("https://example.com/ghe/api/v3/"))
+		})
+	})
+
+	Context("given an scp-like SSH URL", func() {
+		It("should parse the host and path", func() {
+			repo.Spec.URL = "git@github.com:rudoi/alaska-test.git"
+			Expect(factory.Validate(repo)).To(Succeed())
+		})
+	})
+
+	Context("given plain git URLs", func() {
+		BeforeEach(func() {
+			repo.Spec.Provider = alphav1.ProviderGit
+		})
+
+		It("should accept URLs git can fetch without running commands", func() {
+			for _, url := range []string{"https://git.example.com/team/manifests.git", "ssh://git@git.example.com:2222/team/manifests.git",
+				"git://git.example.com/manifests.git", "http://git.example.com/manifests.git", "git@git.example.com:team/manifests.git"} {
+				repo.Spec.URL = url
+				Expect(factory.Validate(repo)).To(Succeed(), url)
+			}
+		})
+
+		It("should reject options, other schemes and hosts ssh reads as options", func() {
+			for _, url := range []string{"", "--upload-pack=touch /tmp/x", "-oProxyCommand=x:repo", "ext::sh -c touch% /tmp/x",
+				"file:///srv/git/manifests.git", "ssh://-oProxyCommand=x/repo", "ssh://-x@git.example.com/repo", "git.example.com:-x"} {
+				repo.Spec.URL = url
+				Expect(factory.Validate(repo)).NotTo(Succeed(), url)
+				_, err := factory.ForRepo(repo, nil)
+				Expect(err).To(HaveOccurred(), url)
+			}
+		})
+	})
+
+	Context("given URLs GitHub can't serve", func() {
+		It("should reject them without panicking", func() {
+			for _, url := range []string{"", "https://github.com/rudoi", "https://github.com/rudoi/alaska/tree/master", "not a url"} {
+				repo.Spec.URL = url
+				Expect(factory.Validate(repo)).NotTo(Succeed(), url)
+				_, err := factory.ForRepo(repo, nil)
+				Expect(err).To(HaveOccurred(), url)
+			}
+		})
+	})
+})
@@ -0,0 +1,187 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/go-logr/logr"
+	"github.com/google/go-github/v28/github"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+)
+
+// Path is the path GitHub push events are expected on
+const Path = "/github/push"
+
+// MaxPayloadBytes is the largest request body read, GitHub caps webhook
+// payloads at 25MB
+const MaxPayloadBytes = 25 << 20
+
+// Receiver accepts GitHub push events and queues the Repos they affect for reconcile
+type Receiver struct {
+	Client client.Client
+	Log    logr.Logger
+
+	// Addr is the address the receiver listens on
+	Addr string
+
+	// Secret is the webhook secret used to check X-Hub-Signature. It's
+	// required: go-github skips the check for an empty secret.
+	Secret []byte
+
+	// Events receives one GenericEvent for every Repo matching a push. Sends
+	// never block the response: give it a buffer, a push that doesn't fit is
+	// dropped and the Repo is picked up by its next poll instead.
+	Events chan<- event.GenericEvent
+}
+
+// Start serves push events until the stop channel is closed
+func (r *Receiver) Start(stop <-chan struct{}) error {
+	if len(r.Secret) == 0 {
+		return errors.New("push receiver needs a webhook secret")
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(Path, r)
+
+	srv := &http.Server{Addr: r.Addr, Handler: mux}
+
+	go func() {
+		<-stop
+		_ = srv.Shutdown(context.Background())
+	}()
+
+	r.Log.Info("serving push events", "addr", r.Addr, "path", Path)
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+func (r *Receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// without a secret, ValidatePayload accepts any payload unsigned
+	if len(r.Secret) == 0 {
+		r.Log.Info("rejecting push event", "reason", "no webhook secret configured")
+		http.Error(w, "webhook secret not configured", http.StatusInternalServerError)
+		return
+	}
+
+	// read one byte more than allowed to tell a payload that's too large apart
+	body, err := ioutil.ReadAll(io.LimitReader(req.Body, MaxPayloadBytes+1))
+	if err != nil {
+		r.Log.Info("rejecting push event", "reason", err.Error())
+		http.Error(w, "unable to read payload", http.StatusBadRequest)
+		return
+	}
+
+	if len(body) > MaxPayloadBytes {
+		r.Log.Info("rejecting push event", "reason", "payload too large")
+		http.Error(w, "payload too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	payload, err := github.ValidatePayload(req, r.Secret)
+	if err != nil {
+		r.Log.Info("rejecting push event", "reason", err.Error())
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+
+	eventType := github.WebHookType(req)
+	if eventType != "push" {
+		// ping and any other events we were subscribed to are acknowledged and dropped
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	parsed, err := github.ParseWebHook(eventType, payload)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	pushEvent := parsed.(*github.PushEvent)
+	if !strings.HasPrefix(pushEvent.GetRef(), "refs/heads/") {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	branch := strings.TrimPrefix(pushEvent.GetRef(), "refs/heads/")
+
+	repos := &alphav1.RepoList{}
+	if err := r.Client.List(req.Context(), repos); err != nil {
+		r.Log.Error(err, "unable to list repos")
+		http.Error(w, "unable to list repos", http.StatusInternalServerError)
+		return
+	}
+
+	pushed := pushEvent.GetRepo()
+	urls := []string{pushed.GetCloneURL(), pushed.GetHTMLURL(), pushed.GetSSHURL(), pushed.GetGitURL()}
+
+	for i := range repos.Items {
+		repo := &repos.Items[i]
+		if repo.Spec.Branch != branch || !matchesAny(repo.Spec.URL, urls) {
+			continue
+		}
+
+		select {
+		case r.Events <- event.GenericEvent{Meta: repo, Object: repo}:
+			r.Log.Info("push received", "repo", repo.GetNamespace()+"/"+repo.GetName(), "branch", branch, "head", pushEvent.GetAfter())
+		default:
+			r.Log.Info("push dropped, queue is full", "repo", repo.GetNamespace()+"/"+repo.GetName(), "branch", branch, "head", pushEvent.GetAfter())
+		}
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func matchesAny(repoURL string, urls []string) bool {
+	want := normalize(repoURL)
+	if want == "" {
+		return false
+	}
+
+	for _, u := range urls {
+		if normalize(u) == want {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalize reduces a clone, html or ssh URL to host/path so the different
+// forms GitHub sends in a push event compare equal to a Repo's spec.url
+func normalize(raw string) string {
+	if raw == "" {
+		return ""
+	}
+
+	// scp-like ssh remotes, e.g. git@github.com:rudoi/alaska.git
+	if !strings.Contains(raw, "://") {
+		if i := strings.Index(raw, ":"); i > 0 {
+			raw = "ssh://" + raw[:i] + "/" + raw[i+1:]
+		}
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return ""
+	}
+
+	return strings.ToLower(u.Hostname() + "/" + strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git"))
+}
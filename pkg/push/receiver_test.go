@@ -0,0 +1,174 @@
+package push
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	alphav1 "github.com/rudoi/alaska/api/v1"
+)
+
+const pushPayload = `{
+  "ref": "refs/heads/master",
+  "after": "0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c",
+  "repository": {
+    "clone_url": "https://github.com/rudoi/alaska-test.git",
+    "html_url": "https://github.com/rudoi/alaska-test",
+    "ssh_url": "git@github.com:rudoi/alaska-test.git"
+  }
+}`
+
+func sign(secret, body []byte) string {
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(body)
+	return "sha1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// failingReader fails every read, like a connection reset mid-request
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset by peer")
+}
+
+func newRepo(name, url, branch string) *alphav1.Repo {
+	return &alphav1.Repo{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec:       alphav1.RepoSpec{URL: url, Branch: branch, Cluster: "pizza"},
+	}
+}
+
+var _ = Describe("Receiver tests", func() {
+	var (
+		receiver *Receiver
+		events   chan event.GenericEvent
+		secret   = []byte("hunter2")
+	)
+
+	BeforeEach(func() {
+		scheme := runtime.NewScheme()
+		Expect(alphav1.AddToScheme(scheme)).To(Succeed())
+
+		events = make(chan event.GenericEvent, 10)
+		receiver = &Receiver{
+			Client: fake.NewFakeClientWithScheme(scheme,
+				newRepo("https", "https://github.com/rudoi/alaska-test.git", "master"),
+				newRepo("ssh", "git@github.com:rudoi/alaska-test.git", "master"),
+				newRepo("other-branch", "https://github.com/rudoi/alaska-test.git", "develop"),
+				newRepo("other-repo", "https://github.com/rudoi/alaska.git", "master"),
+			),
+			Log:    logf.Log,
+			Secret: secret,
+			Events: events,
+		}
+	})
+
+	send := func(eventType, signature string) int {
+		req := httptest.NewRequest(http.MethodPost, Path, bytes.NewBufferString(pushPayload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-GitHub-Event", eventType)
+		req.Header.Set("X-Hub-Signature", signature)
+
+		rec := httptest.NewRecorder()
+		receiver.ServeHTTP(rec, req)
+		close(events)
+		return rec.Code
+	}
+
+	received := func() (names []string) {
+		for evt := range events {
+			names = append(names, evt.Meta.GetName())
+		}
+		return
+	}
+
+	Context("given a correctly signed push event", func() {
+		It("should queue only the Repos on the pushed URL and branch", func() {
+			Expect(send("push", sign(secret, []byte(pushPayload)))).To(Equal(http.StatusAccepted))
+			Expect(received()).To(ConsistOf("https", "ssh"))
+		})
+	})
+
+	Context("given a push event with a bad signature", func() {
+		It("should reject the request and queue nothing", func() {
+			Expect(send("push", sign([]byte("wrong"), []byte(pushPayload)))).To(Equal(http.StatusForbidden))
+			Expect(received()).To(BeEmpty())
+		})
+	})
+
+	Context("given more matching Repos than the queue holds", func() {
+		It("should accept the push without waiting and drop the rest", func() {
+			events = make(chan event.GenericEvent, 1)
+			receiver.Events = events
+
+			Expect(send("push", sign(secret, []byte(pushPayload)))).To(Equal(http.StatusAccepted))
+			Expect(received()).To(HaveLen(1))
+		})
+	})
+
+	Context("given a payload larger than MaxPayloadBytes", func() {
+		It("should reject it before checking the signature", func() {
+			body := bytes.Repeat([]byte(" "), MaxPayloadBytes+1)
+			req := httptest.NewRequest(http.MethodPost, Path, bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-GitHub-Event", "push")
+			req.Header.Set("X-Hub-Signature", sign(secret, body))
+
+			rec := httptest.NewRecorder()
+			receiver.ServeHTTP(rec, req)
+			close(events)
+
+			Expect(rec.Code).To(Equal(http.StatusRequestEntityTooLarge))
+			Expect(received()).To(BeEmpty())
+		})
+	})
+
+	Context("given a body that can't be read", func() {
+		It("should reject it as a bad request", func() {
+			req := httptest.NewRequest(http.MethodPost, Path, ioutil.NopCloser(&failingReader{}))
+			req.Header.Set("X-GitHub-Event", "push")
+
+			rec := httptest.NewRecorder()
+			receiver.ServeHTTP(rec, req)
+			close(events)
+
+			Expect(rec.Code).To(Equal(http.StatusBadRequest))
+			Expect(received()).To(BeEmpty())
+		})
+	})
+
+	Context("given no webhook secret", func() {
+		BeforeEach(func() {
+			receiver.Secret = nil
+		})
+
+		It("should reject even unsigned pushes", func() {
+			Expect(send("push", "")).To(Equal(http.StatusInternalServerError))
+			Expect(received()).To(BeEmpty())
+		})
+
+		It("should refuse to start", func() {
+			Expect(receiver.Start(make(chan struct{}))).To(MatchError(ContainSubstring("webhook secret")))
+		})
+	})
+
+	Context("given a ping event", func() {
+		It("should acknowledge it and queue nothing", func() {
+			Expect(send("ping", sign(secret, []byte(pushPayload)))).To(Equal(http.StatusNoContent))
+			Expect(received()).To(BeEmpty())
+		})
+	})
+})